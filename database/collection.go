@@ -7,13 +7,34 @@ import (
 	"hash/crc32"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/altipla-consulting/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+// DefaultMultiChunkSize is the maximum number of rows that PutMulti and DeleteMulti
+// send in a single statement if no other size is configured.
+const DefaultMultiChunkSize = 500
+
+// MySQL limits the number of placeholders in a single prepared statement.
+const maxPlaceholders = 65535
+
 type CollectionOption func(c *Collection)
 
+// WithMultiChunkSize changes the maximum number of rows that PutMulti and DeleteMulti
+// send in a single statement. The size will be reduced automatically if the
+// statement would have too many placeholders.
+func WithMultiChunkSize(size int) CollectionOption {
+	if size < 1 {
+		panic("cannot configure a chunk size less than 1")
+	}
+
+	return func(c *Collection) {
+		c.chunkSize = size
+	}
+}
+
 // Collection represents a table. You can apply further filters and operations
 // to the collection and then query it with one of our read methods (Get, GetAll, ...)
 // or use it to store new items (Put).
@@ -21,11 +42,19 @@ type Collection struct {
 	db            *Database
 	conditions    []Condition
 	orders        []string
+	keyset        []keysetColumn
+	customOrder   bool
 	offset, limit int64
 	model         Model
 	props         []*Property
 	alias         string
 	h             *hooker
+	chunkSize     int
+	softDelete    *Property
+	deleted       deletedMode
+	joins         []*sqlJoin
+	groupBy       []string
+	having        []Condition
 }
 
 func newCollection(db *Database, model Model) *Collection {
@@ -35,10 +64,11 @@ func newCollection(db *Database, model Model) *Collection {
 	}
 
 	c := &Collection{
-		db:    db,
-		model: model,
-		props: props,
-		h:     new(hooker),
+		db:        db,
+		model:     model,
+		props:     props,
+		h:         new(hooker),
+		chunkSize: DefaultMultiChunkSize,
 	}
 
 	return c
@@ -48,15 +78,23 @@ func newCollection(db *Database, model Model) *Collection {
 // the original one.
 func (c *Collection) Clone() *Collection {
 	return &Collection{
-		db:         c.db,
-		conditions: c.conditions,
-		orders:     c.orders,
-		offset:     c.offset,
-		limit:      c.limit,
-		model:      c.model,
-		props:      c.props,
-		alias:      c.alias,
-		h:          c.h,
+		db:          c.db,
+		conditions:  c.conditions,
+		orders:      c.orders,
+		keyset:      c.keyset,
+		customOrder: c.customOrder,
+		offset:      c.offset,
+		limit:       c.limit,
+		model:       c.model,
+		props:       c.props,
+		alias:       c.alias,
+		h:           c.h,
+		chunkSize:   c.chunkSize,
+		softDelete:  c.softDelete,
+		deleted:     c.deleted,
+		joins:       c.joins,
+		groupBy:     c.groupBy,
+		having:      c.having,
 	}
 }
 
@@ -71,16 +109,15 @@ func (c *Collection) Alias(alias string) *Collection {
 // If no model is found ErrNoSuchEntity will be returned and the model won't be touched.
 func (c *Collection) Get(ctx context.Context, instance Model) error {
 	modelProps := updateModelProps(c.props, instance)
-	b := &sqlBuilder{
-		table:      c.model.TableName(),
-		conditions: c.conditions,
-		alias:      c.alias,
-		props:      modelProps,
-	}
+	b := c.readBuilder(modelProps)
 
 	for _, prop := range modelProps {
 		if prop.PrimaryKey {
-			b.conditions = append(b.conditions, Filter(prop.UnescapedName, prop.Value))
+			if len(c.joins) > 0 {
+				b.conditions = append(b.conditions, Filter(b.qualifier()+"."+prop.Name+" =", prop.Value))
+			} else {
+				b.conditions = append(b.conditions, Filter(prop.UnescapedName, prop.Value))
+			}
 		}
 	}
 
@@ -93,7 +130,7 @@ func (c *Collection) Get(ctx context.Context, instance Model) error {
 	for _, prop := range modelProps {
 		pointers = append(pointers, prop.Pointer)
 	}
-	if err := c.db.executor(ctx).QueryRowContext(ctx, statement, values...).Scan(pointers...); err != nil {
+	if err := c.db.reader(ctx).QueryRowContext(ctx, statement, values...).Scan(pointers...); err != nil {
 		if err == sql.ErrNoRows {
 			return ErrNoSuchEntity
 		}
@@ -103,7 +140,11 @@ func (c *Collection) Get(ctx context.Context, instance Model) error {
 
 	modelProps = updateModelProps(c.props, instance)
 
-	return instance.Tracking().AfterGet(modelProps)
+	if err := instance.Tracking().AfterGet(modelProps); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(c.h.runAfterGet(ctx, instance))
 }
 
 // Put stores a new item of the collection. Any filter or limit of the
@@ -115,10 +156,8 @@ func (c *Collection) Put(ctx context.Context, instance Model) error {
 		return errors.Errorf("expected instance of %s and got a instance of %s", modelt, instancet)
 	}
 
-	if h, ok := instance.(OnBeforePutHooker); ok {
-		if err := h.OnBeforePutHook(); err != nil {
-			return errors.Trace(err)
-		}
+	if err := c.beforePut(ctx, instance); err != nil {
+		return errors.Trace(err)
 	}
 
 	b := &sqlBuilder{
@@ -126,8 +165,6 @@ func (c *Collection) Put(ctx context.Context, instance Model) error {
 	}
 	modelProps := updateModelProps(c.props, instance)
 
-	var q string
-	var values []interface{}
 	if instance.Tracking().IsInserted() {
 		b.conditions = append(b.conditions, c.conditions...)
 		b.conditions = append(b.conditions, Filter("revision", instance.Tracking().StoredRevision()))
@@ -145,7 +182,25 @@ func (c *Collection) Put(ctx context.Context, instance Model) error {
 			b.props = append(b.props, prop)
 		}
 
-		q, values = b.UpdateSQL()
+		q, values := b.UpdateSQL()
+		if c.db.debug {
+			log.WithFields(log.Fields{
+				"query":  q,
+				"params": fmt.Sprintf("%#v", values),
+			}).Debug("Put instance")
+		}
+
+		result, err := c.db.executor(ctx).ExecContext(ctx, q, values...)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("cannot get rows affected: %v", err)
+		}
+		if rows == 0 {
+			return ErrConcurrentTransaction
+		}
 	} else {
 		for _, prop := range modelProps {
 			if prop.OmitEmpty && isZero(prop.Value) {
@@ -155,52 +210,25 @@ func (c *Collection) Put(ctx context.Context, instance Model) error {
 			b.props = append(b.props, prop)
 		}
 
-		q, values = b.InsertSQL()
-	}
-
-	if c.db.debug {
-		log.WithFields(log.Fields{
-			"query":  q,
-			"params": fmt.Sprintf("%#v", values),
-		}).Debug("Put instance")
-	}
-
-	result, err := c.db.executor(ctx).ExecContext(ctx, q, values...)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	var pks int
-	for _, prop := range modelProps {
-		if prop.PrimaryKey {
-			pks++
+		if err := c.insert(ctx, b.props, modelProps); err != nil {
+			return errors.Trace(err)
 		}
 	}
-	if pks == 1 && !instance.Tracking().IsInserted() {
-		id, err := result.LastInsertId()
-		if err != nil {
-			return fmt.Errorf("cannot get last inserted id: %v", err)
-		}
 
-		if id != 0 {
-			for _, prop := range modelProps {
-				if prop.PrimaryKey {
-					if _, ok := prop.Value.(int64); ok {
-						reflect.ValueOf(prop.Pointer).Elem().Set(reflect.ValueOf(id))
-					}
-				}
-			}
+	return errors.Trace(c.afterPut(ctx, instance, modelProps))
+}
+
+func (c *Collection) beforePut(ctx context.Context, instance Model) error {
+	if h, ok := instance.(OnBeforePutHooker); ok {
+		if err := h.OnBeforePutHook(); err != nil {
+			return errors.Trace(err)
 		}
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("cannot get rows affected: %v", err)
-	}
-	if rows == 0 {
-		return ErrConcurrentTransaction
-	}
+	return errors.Trace(c.h.runBeforePut(ctx, instance))
+}
 
+func (c *Collection) afterPut(ctx context.Context, instance Model, modelProps []*Property) error {
 	if err := instance.Tracking().AfterPut(modelProps); err != nil {
 		return errors.Trace(err)
 	}
@@ -249,10 +277,19 @@ func (c *Collection) FilterCond(condition Condition) *Collection {
 		return c
 	}
 
-	c.conditions = append(c.conditions, condition)
+	c.conditions = append(c.conditions, c.resolveCondition(condition))
 	return c
 }
 
+// resolveCondition builds the SQL of the conditions that depend on the dialect
+// of the database.
+func (c *Collection) resolveCondition(condition Condition) Condition {
+	if dc, ok := condition.(dialectCondition); ok && c.db != nil {
+		return &sqlCondition{dc.dialectSQL(c.db.dialect), dc.Values()}
+	}
+	return condition
+}
+
 // Offset moves the initial position of the query. In combination with Limit
 // it allows you to paginate the results.
 func (c *Collection) Offset(offset int64) *Collection {
@@ -280,13 +317,13 @@ func (c *Collection) Order(column string) *Collection {
 		panic("do not call Order with `foo DESC`, use plain `-foo` instead")
 	}
 
+	col := keysetColumn{name: column}
 	if strings.HasPrefix(column, "-") {
-		column = fmt.Sprintf("`%s` DESC", column[1:])
-	} else {
-		column = fmt.Sprintf("`%s` ASC", column)
+		col = keysetColumn{name: column[1:], desc: true}
 	}
 
-	c.orders = append(c.orders, column)
+	c.keyset = append(c.keyset, col)
+	c.orders = append(c.orders, col.orderSQL())
 	return c
 }
 
@@ -294,6 +331,7 @@ func (c *Collection) Order(column string) *Collection {
 // in this library to build sorters; and other libraries (like github.com/altipla-consulting/geo)
 // can implement their own sorters too.
 func (c *Collection) OrderSorter(sorter Sorter) *Collection {
+	c.customOrder = true
 	c.orders = append(c.orders, sorter.SQL())
 	return c
 }
@@ -302,7 +340,33 @@ func (c *Collection) OrderSorter(sorter Sorter) *Collection {
 // primary key to find the row to remove, so it can return an error even if the
 // PK exists when the filters do not match. Limits won't be applied but the offset
 // of the collection will.
+//
+// If the collection has soft deletes enabled the row will be kept and only the
+// deleted column will be stamped with the current time.
 func (c *Collection) Delete(ctx context.Context, instance Model) error {
+	if c.softDelete == nil {
+		return errors.Trace(c.HardDelete(ctx, instance))
+	}
+
+	if err := c.h.runBeforeDelete(ctx, instance); err != nil {
+		return errors.Trace(err)
+	}
+
+	deleted := sql.NullTime{Time: time.Now(), Valid: true}
+	if err := c.updateDeleted(ctx, []Model{instance}, deleted); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(c.h.runAfterDelete(ctx, instance))
+}
+
+// HardDelete removes the row of a model from the collection even if the collection
+// has soft deletes enabled. See Delete for the rest of the documentation.
+func (c *Collection) HardDelete(ctx context.Context, instance Model) error {
+	if err := c.h.runBeforeDelete(ctx, instance); err != nil {
+		return errors.Trace(err)
+	}
+
 	b := &sqlBuilder{
 		table:      c.model.TableName(),
 		conditions: c.conditions,
@@ -327,33 +391,42 @@ func (c *Collection) Delete(ctx context.Context, instance Model) error {
 		return errors.Trace(err)
 	}
 
-	return instance.Tracking().AfterDelete(modelProps)
+	return errors.Trace(c.afterDelete(ctx, instance, modelProps))
+}
+
+func (c *Collection) afterDelete(ctx context.Context, instance Model, modelProps []*Property) error {
+	if err := instance.Tracking().AfterDelete(modelProps); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(c.h.runAfterDelete(ctx, instance))
 }
 
 // Iterator returns a new iterator that can be used to extract models one by one in a loop.
 // You should close the Iterator after you are done with it.
 func (c *Collection) Iterator(ctx context.Context) (*Iterator, error) {
-	b := &sqlBuilder{
-		table:      c.model.TableName(),
-		conditions: c.conditions,
-		props:      c.props,
-		limit:      c.limit,
-		offset:     c.offset,
-		orders:     c.orders,
-		alias:      c.alias,
-	}
+	b := c.readBuilder(c.props)
+	b.limit = c.limit
+	b.offset = c.offset
+	b.orders = c.orders
 
 	sqlStmt, values := b.SelectSQL()
 	if c.db.debug {
 		log.Println("database [Iterator]:", sqlStmt)
 	}
 
-	rows, err := c.db.executor(ctx).QueryContext(ctx, sqlStmt, values...)
+	rows, err := c.db.reader(ctx).QueryContext(ctx, sqlStmt, values...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Iterator{rows, c.props}, nil
+	it := &Iterator{
+		ctx:   ctx,
+		rows:  rows,
+		props: c.props,
+		h:     c.h,
+	}
+	return it, nil
 }
 
 // GetAll receives a pointer to an empty slice of models and retrieves all the
@@ -409,15 +482,10 @@ func (c *Collection) First(ctx context.Context, instance Model) error {
 	c = c.Limit(1)
 
 	modelProps := updateModelProps(c.props, instance)
-	b := &sqlBuilder{
-		table:      c.model.TableName(),
-		conditions: c.conditions,
-		props:      modelProps,
-		limit:      c.limit,
-		offset:     c.offset,
-		orders:     c.orders,
-		alias:      c.alias,
-	}
+	b := c.readBuilder(modelProps)
+	b.limit = c.limit
+	b.offset = c.offset
+	b.orders = c.orders
 
 	statement, values := b.SelectSQL()
 	if c.db.debug {
@@ -428,7 +496,7 @@ func (c *Collection) First(ctx context.Context, instance Model) error {
 	for _, prop := range modelProps {
 		pointers = append(pointers, prop.Pointer)
 	}
-	if err := c.db.executor(ctx).QueryRowContext(ctx, statement, values...).Scan(pointers...); err != nil {
+	if err := c.db.reader(ctx).QueryRowContext(ctx, statement, values...).Scan(pointers...); err != nil {
 		if err == sql.ErrNoRows {
 			return ErrNoSuchEntity
 		}
@@ -438,24 +506,30 @@ func (c *Collection) First(ctx context.Context, instance Model) error {
 
 	modelProps = updateModelProps(c.props, instance)
 
-	return instance.Tracking().AfterGet(modelProps)
+	if err := instance.Tracking().AfterGet(modelProps); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(c.h.runAfterGet(ctx, instance))
 }
 
 // Count queries the number of rows that the collection matches.
+//
+// If the collection is grouped it counts the number of groups instead.
 func (c *Collection) Count(ctx context.Context) (int64, error) {
-	b := &sqlBuilder{
-		table:      c.model.TableName(),
-		conditions: c.conditions,
-		alias:      c.alias,
-	}
+	b := c.readBuilder(nil)
 
 	sqlStmt, values := b.SelectSQLCols("COUNT(*)")
+	if len(c.groupBy) > 0 {
+		sqlStmt, values = b.SelectSQLCols("NULL")
+		sqlStmt = fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS grouped", sqlStmt)
+	}
 	if c.db.debug {
 		log.Println("database [Count]:", sqlStmt)
 	}
 
 	var n int64
-	if err := c.db.executor(ctx).QueryRowContext(ctx, sqlStmt, values...).Scan(&n); err != nil {
+	if err := c.db.reader(ctx).QueryRowContext(ctx, sqlStmt, values...).Scan(&n); err != nil {
 		return 0, err
 	}
 
@@ -570,14 +644,481 @@ func (c *Collection) GetMulti(ctx context.Context, keys interface{}, models inte
 	return nil
 }
 
+// PutMulti stores multiple items of the collection using a few multi-row statements
+// instead of one statement per model. Models should be a slice of models. Updated
+// models will check their revision like Put does. If any of the models fails a
+// MultiError will be returned with the errors in the same order as the models and
+// nil's for the ones stored successfully. Each chunk of models is stored in its own
+// transaction; if one fails its models and the following ones receive the error.
+//
+// Models with an empty auto increment primary key are inserted one by one to
+// retrieve their new IDs.
+func (c *Collection) PutMulti(ctx context.Context, models interface{}) error {
+	instances, err := c.multiInstances(models, "PutMulti")
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	merr := make(MultiError, len(instances))
+	var pending []int
+	for i, instance := range instances {
+		if err := c.beforePut(ctx, instance); err != nil {
+			merr[i] = errors.Trace(err)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	size := c.multiChunkSize(len(c.props))
+	for start := 0; start < len(pending); start += size {
+		chunk := pending[start:min(start+size, len(pending))]
+
+		fn := func(ctx context.Context) error {
+			return errors.Trace(c.putChunk(ctx, instances, chunk, merr))
+		}
+		if err := c.db.inTransaction(ctx, fn); err != nil {
+			return failPending(merr, pending[start:], errors.Trace(err))
+		}
+
+		for _, i := range chunk {
+			if merr[i] != nil {
+				continue
+			}
+			merr[i] = c.afterPut(ctx, instances[i], updateModelProps(c.props, instances[i]))
+		}
+	}
+
+	if merr.HasError() {
+		return merr
+	}
+	return nil
+}
+
+type putGroup struct {
+	cols []*Property
+	rows [][]*Property
+}
+
+func (c *Collection) putChunk(ctx context.Context, instances []Model, chunk []int, merr MultiError) error {
+	models := make([]Model, len(chunk))
+	for i, idx := range chunk {
+		models[i] = instances[idx]
+	}
+
+	// Lock the existing rows to check the revisions before writing anything.
+	var cols []*Property
+	for _, prop := range c.props {
+		if prop.PrimaryKey || prop.UnescapedName == "revision" {
+			cols = append(cols, prop)
+		}
+	}
+	b := &sqlBuilder{
+		table:      c.model.TableName(),
+		conditions: []Condition{c.pkCondition(models)},
+		props:      cols,
+	}
+	statement, values := b.SelectSQL()
+	if forUpdate := c.db.dialect.ForUpdateSQL(); forUpdate != "" {
+		statement += " " + forUpdate
+	}
+	existing, err := c.queryRevisions(ctx, statement, values)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// Updates should also match the filters of the collection like Put does.
+	matching := existing
+	if len(c.conditions) > 0 {
+		b.conditions = append([]Condition{b.conditions[0]}, c.conditions...)
+		statement, values := b.SelectSQL()
+		matching, err = c.queryRevisions(ctx, statement, values)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	var autoIncrement []int
+	var signatures []string
+	groups := make(map[string]*putGroup)
+	for _, i := range chunk {
+		instance := instances[i]
+		modelProps := updateModelProps(c.props, instance)
+		key := pkKey(modelProps)
+
+		if instance.Tracking().IsInserted() {
+			revision, ok := matching[key]
+			if !ok || revision != instance.Tracking().StoredRevision() {
+				merr[i] = ErrConcurrentTransaction
+				continue
+			}
+		} else {
+			if isAutoIncrement(modelProps) {
+				autoIncrement = append(autoIncrement, i)
+				continue
+			}
+			if _, ok := existing[key]; ok {
+				merr[i] = errors.Errorf("duplicate primary key %s in table %s", key, c.model.TableName())
+				continue
+			}
+		}
+
+		var row []*Property
+		var names []string
+		for _, prop := range modelProps {
+			if !prop.PrimaryKey && prop.OmitEmpty && isZero(prop.Value) {
+				continue
+			}
+			row = append(row, prop)
+			names = append(names, prop.Name)
+		}
+
+		signature := strings.Join(names, ",")
+		group, ok := groups[signature]
+		if !ok {
+			group = &putGroup{cols: row}
+			groups[signature] = group
+			signatures = append(signatures, signature)
+		}
+		group.rows = append(group.rows, row)
+	}
+
+	for _, signature := range signatures {
+		group := groups[signature]
+		b := &sqlBuilder{
+			table: c.model.TableName(),
+			props: group.cols,
+		}
+		q, values := b.UpsertMultiSQL(group.rows, c.db.dialect)
+		if c.db.debug {
+			log.WithFields(log.Fields{
+				"query":  q,
+				"params": fmt.Sprintf("%#v", values),
+			}).Debug("Put multiple instances")
+		}
+
+		if _, err := c.db.executor(ctx).ExecContext(ctx, q, values...); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	for _, i := range autoIncrement {
+		modelProps := updateModelProps(c.props, instances[i])
+		var props []*Property
+		for _, prop := range modelProps {
+			if prop.OmitEmpty && isZero(prop.Value) {
+				continue
+			}
+			props = append(props, prop)
+		}
+
+		if err := c.insert(ctx, props, modelProps); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteMulti removes multiple models from a collection using a few statements
+// instead of one statement per model. It uses the filters and the models primary
+// keys to find the rows to remove. Models should be a slice of models. If any of
+// the models fails a MultiError will be returned with the errors in the same order
+// as the models and nil's for the ones removed successfully. If a chunk of models
+// fails, its models and the following ones receive the error.
+func (c *Collection) DeleteMulti(ctx context.Context, models interface{}) error {
+	instances, err := c.multiInstances(models, "DeleteMulti")
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var pks int
+	for _, prop := range c.props {
+		if prop.PrimaryKey {
+			pks++
+		}
+	}
+
+	merr := make(MultiError, len(instances))
+	var pending []int
+	for i, instance := range instances {
+		if err := c.h.runBeforeDelete(ctx, instance); err != nil {
+			merr[i] = errors.Trace(err)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	size := c.multiChunkSize(pks)
+	for start := 0; start < len(pending); start += size {
+		chunk := pending[start:min(start+size, len(pending))]
+		models := make([]Model, len(chunk))
+		for i, idx := range chunk {
+			models[i] = instances[idx]
+		}
+
+		if c.softDelete != nil {
+			deleted := sql.NullTime{Time: time.Now(), Valid: true}
+			if err := c.updateDeleted(ctx, models, deleted); err != nil {
+				return failPending(merr, pending[start:], errors.Trace(err))
+			}
+			for _, i := range chunk {
+				merr[i] = c.h.runAfterDelete(ctx, instances[i])
+			}
+			continue
+		}
+
+		conditions := make([]Condition, len(c.conditions), len(c.conditions)+1)
+		copy(conditions, c.conditions)
+		b := &sqlBuilder{
+			table:      c.model.TableName(),
+			conditions: append(conditions, c.pkCondition(models)),
+			alias:      c.alias,
+		}
+		statement, values := b.DeleteSQL()
+		if c.db.debug {
+			log.Println("database [DeleteMulti]:", statement)
+		}
+
+		if _, err := c.db.executor(ctx).ExecContext(ctx, statement, values...); err != nil {
+			return failPending(merr, pending[start:], errors.Trace(err))
+		}
+
+		for _, i := range chunk {
+			merr[i] = c.afterDelete(ctx, instances[i], updateModelProps(c.props, instances[i]))
+		}
+	}
+
+	if merr.HasError() {
+		return merr
+	}
+	return nil
+}
+
+func (c *Collection) multiInstances(models interface{}, method string) ([]Model, error) {
+	v := reflect.ValueOf(models)
+	if v.Kind() != reflect.Slice {
+		return nil, errors.Errorf("pass a slice of models to %s", method)
+	}
+	modelt := reflect.TypeOf(c.model)
+	if v.Type().Elem() != modelt {
+		return nil, errors.Errorf("expected a slice of %s and got a slice of %s", modelt, v.Type().Elem())
+	}
+
+	instances := make([]Model, v.Len())
+	for i := range instances {
+		instances[i] = v.Index(i).Interface().(Model)
+	}
+	return instances, nil
+}
+
+// failPending assigns the error of a failed chunk to its models and the ones of
+// the following chunks. Previous chunks were already applied and keep their result.
+func failPending(merr MultiError, pending []int, err error) MultiError {
+	for _, i := range pending {
+		if merr[i] == nil {
+			merr[i] = err
+		}
+	}
+	return merr
+}
+
+func (c *Collection) multiChunkSize(placeholders int) int {
+	size := c.chunkSize
+	if placeholders > 0 && size*placeholders > maxPlaceholders {
+		size = maxPlaceholders / placeholders
+	}
+	return size
+}
+
+func (c *Collection) pkCondition(instances []Model) Condition {
+	var pks []*Property
+	for _, prop := range c.props {
+		if prop.PrimaryKey {
+			pks = append(pks, prop)
+		}
+	}
+
+	if len(pks) == 1 {
+		keys := make([]interface{}, len(instances))
+		for i, instance := range instances {
+			keys[i] = reflect.ValueOf(instance).Elem().FieldByName(pks[0].Field).Interface()
+		}
+		return Filter(fmt.Sprintf("%s IN", pks[0].Name), keys)
+	}
+
+	var names, placeholders []string
+	for _, pk := range pks {
+		names = append(names, pk.Name)
+		placeholders = append(placeholders, "?")
+	}
+	group := "(" + strings.Join(placeholders, ", ") + ")"
+
+	var values []interface{}
+	groups := make([]string, len(instances))
+	for i, instance := range instances {
+		groups[i] = group
+		for _, pk := range pks {
+			values = append(values, reflect.ValueOf(instance).Elem().FieldByName(pk.Field).Interface())
+		}
+	}
+
+	return &sqlCondition{
+		sql:    fmt.Sprintf("(%s) IN (%s)", strings.Join(names, ", "), strings.Join(groups, ", ")),
+		values: values,
+	}
+}
+
+// queryRevisions returns the revision of the rows indexed by their primary keys.
+func (c *Collection) queryRevisions(ctx context.Context, statement string, values []interface{}) (map[string]int64, error) {
+	if c.db.debug {
+		log.Println("database [PutMulti]:", statement)
+	}
+
+	rows, err := c.db.executor(ctx).QueryContext(ctx, statement, values...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	revisions := make(map[string]int64)
+	for rows.Next() {
+		// The example is only used to have the correct pointers to scan.
+		example := reflect.New(reflect.TypeOf(c.model).Elem()).Interface().(Model)
+		var pointers []interface{}
+		for _, prop := range updateModelProps(c.props, example) {
+			if prop.PrimaryKey || prop.UnescapedName == "revision" {
+				pointers = append(pointers, prop.Pointer)
+			}
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		revisions[pkKey(updateModelProps(c.props, example))] = example.Tracking().Revision
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return revisions, nil
+}
+
+func pkKey(props []*Property) string {
+	var values []interface{}
+	for _, prop := range props {
+		if prop.PrimaryKey {
+			values = append(values, prop.Value)
+		}
+	}
+	return fmt.Sprintf("%#v", values)
+}
+
+func isAutoIncrement(props []*Property) bool {
+	var pk *Property
+	for _, prop := range props {
+		if prop.PrimaryKey {
+			if pk != nil {
+				return false
+			}
+			pk = prop
+		}
+	}
+	if pk == nil {
+		return false
+	}
+	id, ok := pk.Value.(int64)
+	return ok && id == 0
+}
+
+// insert stores a new row with the props and assigns the generated autoincrement
+// primary key to the model if there is one.
+func (c *Collection) insert(ctx context.Context, props, modelProps []*Property) error {
+	b := &sqlBuilder{
+		table: c.model.TableName(),
+	}
+
+	// Some dialects read the generated key with a RETURNING clause and would store
+	// the zero value if we send it.
+	var returning string
+	var pk *Property
+	if isAutoIncrement(modelProps) {
+		for _, prop := range modelProps {
+			if prop.PrimaryKey {
+				pk = prop
+			}
+		}
+		returning = c.db.dialect.ReturningSQL(pk.Name)
+	}
+	for _, prop := range props {
+		if returning != "" && prop == pk {
+			continue
+		}
+		b.props = append(b.props, prop)
+	}
+
+	q, values := b.InsertSQL()
+	if returning != "" {
+		q += " " + returning
+	}
+	if c.db.debug {
+		log.WithFields(log.Fields{
+			"query":  q,
+			"params": fmt.Sprintf("%#v", values),
+		}).Debug("Put instance")
+	}
+
+	if returning != "" {
+		return errors.Trace(c.db.executor(ctx).QueryRowContext(ctx, q, values...).Scan(pk.Pointer))
+	}
+
+	result, err := c.db.executor(ctx).ExecContext(ctx, q, values...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(assignLastInsertID(result, modelProps))
+}
+
+func assignLastInsertID(result sql.Result, props []*Property) error {
+	var pks int
+	for _, prop := range props {
+		if prop.PrimaryKey {
+			pks++
+		}
+	}
+	if pks != 1 {
+		return nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("cannot get last inserted id: %v", err)
+	}
+
+	if id != 0 {
+		for _, prop := range props {
+			if prop.PrimaryKey {
+				if _, ok := prop.Value.(int64); ok {
+					reflect.ValueOf(prop.Pointer).Elem().Set(reflect.ValueOf(id))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // Truncate removes every single row of a table. It also resets any autoincrement
 // value it may have to the value "1".
 func (c *Collection) Truncate(ctx context.Context) error {
-	if _, err := c.db.executor(ctx).ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s`, c.model.TableName())); err != nil {
-		return errors.Trace(err)
+	for _, statement := range c.db.dialect.TruncateSQL(c.model.TableName()) {
+		if _, err := c.db.executor(ctx).ExecContext(ctx, statement); err != nil {
+			return errors.Trace(err)
+		}
 	}
-	if _, err := c.db.executor(ctx).ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s AUTO_INCREMENT = 1`, c.model.TableName())); err != nil {
-		return errors.Trace(err)
+	if resetter, ok := c.db.dialect.(sequenceResetter); ok {
+		if err := resetter.resetSequence(ctx, c.db.executor(ctx), c.model.TableName()); err != nil {
+			return errors.Trace(err)
+		}
 	}
 
 	return nil
@@ -595,6 +1136,20 @@ func (c *Collection) FilterNotExists(sub *Collection, join string) *Collection {
 	return c.FilterCond(FilterNotExists(sub, join))
 }
 
+// readBuilder prepares the query of the read operations, with the joins and
+// groups of the collection.
+func (c *Collection) readBuilder(props []*Property) *sqlBuilder {
+	return &sqlBuilder{
+		table:      c.model.TableName(),
+		conditions: c.queryConditions(),
+		props:      props,
+		alias:      c.alias,
+		joins:      c.joins,
+		groupBy:    c.groupBy,
+		having:     c.having,
+	}
+}
+
 // Checksum returns a checksum of the filters, conditions, table name, ... and other
 // internal data of the collection that identifies it. It won't include the
 // columns, so you can add or remove them without busting the checksums.
@@ -602,7 +1157,21 @@ func (c *Collection) Checksum() uint32 {
 	var checksum []string
 	checksum = append(checksum, c.model.TableName())
 	checksum = append(checksum, c.orders...)
-	for _, cond := range c.conditions {
+	for _, cond := range c.queryConditions() {
+		checksum = append(checksum, cond.SQL())
+		for _, v := range cond.Values() {
+			checksum = append(checksum, fmt.Sprintf("%v", v))
+		}
+	}
+	for _, join := range c.joins {
+		joinSQL, joinValues := join.SQL()
+		checksum = append(checksum, joinSQL)
+		for _, v := range joinValues {
+			checksum = append(checksum, fmt.Sprintf("%v", v))
+		}
+	}
+	checksum = append(checksum, c.groupBy...)
+	for _, cond := range c.having {
 		checksum = append(checksum, cond.SQL())
 		for _, v := range cond.Values() {
 			checksum = append(checksum, fmt.Sprintf("%v", v))
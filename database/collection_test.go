@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/altipla-consulting/errors"
@@ -686,6 +688,204 @@ func TestGetMultiEmpty(t *testing.T) {
 	require.Nil(t, testings.GetMulti(ctx, []string{}, &models))
 }
 
+func TestPutMulti(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingModel{
+		{Code: "foo", Name: "foo name"},
+		{Code: "bar", Name: "bar name"},
+	}
+	require.NoError(t, testings.PutMulti(ctx, models))
+
+	require.True(t, models[0].IsInserted())
+	require.True(t, models[1].IsInserted())
+
+	other := &testingModel{
+		Code: "bar",
+	}
+	require.NoError(t, testings.Get(ctx, other))
+	require.Equal(t, "bar name", other.Name)
+	require.EqualValues(t, 0, other.Tracking().StoredRevision())
+}
+
+func TestPutMultiUpdate(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingModel{
+		{Code: "foo", Name: "foo name"},
+		{Code: "bar", Name: "bar name"},
+	}
+	require.NoError(t, testings.PutMulti(ctx, models))
+
+	models[0].Name = "foo changed"
+	models[1].Name = "bar changed"
+	require.NoError(t, testings.PutMulti(ctx, models))
+
+	var results []*testingModel
+	require.NoError(t, testings.GetAll(ctx, &results))
+	require.Len(t, results, 2)
+	require.Equal(t, "bar changed", results[0].Name)
+	require.EqualValues(t, 1, results[0].Tracking().StoredRevision())
+	require.Equal(t, "foo changed", results[1].Name)
+	require.EqualValues(t, 1, results[1].Tracking().StoredRevision())
+}
+
+func TestPutMultiConcurrentTransaction(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingModel{
+		{Code: "foo", Name: "foo name"},
+		{Code: "bar", Name: "bar name"},
+	}
+	require.NoError(t, testings.PutMulti(ctx, models))
+
+	other := &testingModel{
+		Code: "bar",
+	}
+	require.NoError(t, testings.Get(ctx, other))
+	other.Name = "baz"
+	require.NoError(t, testings.Put(ctx, other))
+
+	models[0].Name = "foo changed"
+	models[1].Name = "bar changed"
+	err := testings.PutMulti(ctx, models)
+	require.Error(t, err)
+
+	var merr MultiError
+	require.True(t, errors.As(err, &merr))
+	require.Len(t, merr, 2)
+	require.NoError(t, merr[0])
+	require.EqualError(t, merr[1], ErrConcurrentTransaction.Error())
+
+	check := &testingModel{
+		Code: "bar",
+	}
+	require.NoError(t, testings.Get(ctx, check))
+	require.Equal(t, "baz", check.Name)
+}
+
+func TestPutMultiAutoIncrement(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingAutoModel{
+		{Name: "foo"},
+		{Name: "bar"},
+	}
+	require.NoError(t, testingsAuto.PutMulti(ctx, models))
+
+	require.EqualValues(t, models[0].ID, 1)
+	require.EqualValues(t, models[1].ID, 2)
+}
+
+func TestPutMultiChunks(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	c := testDB.Collection(new(testingModel), WithMultiChunkSize(2))
+
+	var models []*testingModel
+	for i := 0; i < 5; i++ {
+		models = append(models, &testingModel{Code: fmt.Sprintf("code%d", i)})
+	}
+	require.NoError(t, c.PutMulti(ctx, models))
+
+	n, err := testings.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 5)
+}
+
+func TestPutMultiChunkError(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	c := testDB.Collection(new(testingModel), WithMultiChunkSize(2))
+
+	var models []*testingModel
+	for i := 0; i < 5; i++ {
+		models = append(models, &testingModel{Code: fmt.Sprintf("code%d", i)})
+	}
+	models[2].Name = strings.Repeat("x", 200)
+
+	err := c.PutMulti(ctx, models)
+	var merr MultiError
+	require.True(t, errors.As(err, &merr))
+	require.Len(t, merr, 5)
+	require.NoError(t, merr[0])
+	require.NoError(t, merr[1])
+	require.Error(t, merr[2])
+	require.Error(t, merr[3])
+	require.Error(t, merr[4])
+
+	n, err := testings.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 2)
+}
+
+func TestPutMultiWrongType(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingAutoModel{
+		{Name: "foo"},
+	}
+	require.EqualError(t, testings.PutMulti(ctx, models), "expected a slice of *database.testingModel and got a slice of *database.testingAutoModel")
+}
+
+func TestPutMultiAfterPutHooks(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	var called int
+	fn := func(ctx context.Context, instance Model) error {
+		called++
+		return nil
+	}
+	c := testDB.Collection(new(testingModel), WithAfterPut(fn))
+
+	models := []*testingModel{
+		{Code: "foo"},
+		{Code: "bar"},
+	}
+	require.NoError(t, c.PutMulti(ctx, models))
+
+	require.Equal(t, called, 2)
+}
+
+func TestDeleteMulti(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	models := []*testingModel{
+		{Code: "foo"},
+		{Code: "bar"},
+		{Code: "baz"},
+	}
+	require.NoError(t, testings.PutMulti(ctx, models))
+
+	require.NoError(t, testings.DeleteMulti(ctx, models[:2]))
+
+	require.False(t, models[0].IsInserted())
+	require.False(t, models[1].IsInserted())
+
+	var results []*testingModel
+	require.NoError(t, testings.GetAll(ctx, &results))
+	require.Len(t, results, 1)
+	require.Equal(t, "baz", results[0].Code)
+}
+
 func TestFirst(t *testing.T) {
 	initDatabase(t)
 	defer closeDatabase(t)
@@ -983,3 +1183,10 @@ func TestFilterNotExistsAliasesAfterTheFilter(t *testing.T) {
 
 	require.EqualValues(t, models[0].ID, 1)
 }
+
+func TestIsAutoIncrement(t *testing.T) {
+	require.True(t, isAutoIncrement([]*Property{{Name: "id", PrimaryKey: true, Value: int64(0)}, {Name: "name", Value: "foo"}}))
+	require.False(t, isAutoIncrement([]*Property{{Name: "id", PrimaryKey: true, Value: int64(3)}}))
+	require.False(t, isAutoIncrement([]*Property{{Name: "code", PrimaryKey: true, Value: ""}}))
+	require.False(t, isAutoIncrement([]*Property{{Name: "name", Value: "foo"}}))
+}
@@ -74,7 +74,7 @@ func FilterExists(sub *Collection, join string) Condition {
 	sub = sub.Clone().FilterCond(&sqlCondition{join, nil})
 	b := &sqlBuilder{
 		table:      sub.model.TableName(),
-		conditions: sub.conditions,
+		conditions: sub.queryConditions(),
 		props:      sub.props,
 		limit:      sub.limit,
 		offset:     sub.offset,
@@ -103,7 +103,7 @@ func FilterNotExists(sub *Collection, join string) Condition {
 	sub = sub.Clone().FilterCond(&sqlCondition{join, nil})
 	b := &sqlBuilder{
 		table:      sub.model.TableName(),
-		conditions: sub.conditions,
+		conditions: sub.queryConditions(),
 		props:      sub.props,
 		limit:      sub.limit,
 		offset:     sub.offset,
@@ -132,18 +132,7 @@ func And(children []Condition) Condition {
 	if len(children) == 0 {
 		return new(sqlCondition)
 	}
-
-	sql := make([]string, len(children))
-	values := []interface{}{}
-	for i, child := range children {
-		sql[i] = child.SQL()
-		values = append(values, child.Values()...)
-	}
-
-	return &sqlCondition{
-		sql:    "(" + strings.Join(sql, " AND ") + ")",
-		values: values,
-	}
+	return &logicalCondition{"AND", children}
 }
 
 // Or applies an OR operation between each of the children conditions.
@@ -151,18 +140,38 @@ func Or(children []Condition) Condition {
 	if len(children) == 0 {
 		return new(sqlCondition)
 	}
+	return &logicalCondition{"OR", children}
+}
+
+// logicalCondition keeps the children conditions to build their SQL with the
+// dialect of the collection when some of them depend on it.
+type logicalCondition struct {
+	op       string
+	children []Condition
+}
+
+func (cond *logicalCondition) SQL() string {
+	return cond.dialectSQL(MySQL)
+}
 
-	sql := make([]string, len(children))
+func (cond *logicalCondition) Values() []interface{} {
 	values := []interface{}{}
-	for i, child := range children {
-		sql[i] = child.SQL()
+	for _, child := range cond.children {
 		values = append(values, child.Values()...)
 	}
+	return values
+}
 
-	return &sqlCondition{
-		sql:    "(" + strings.Join(sql, " OR ") + ")",
-		values: values,
+func (cond *logicalCondition) dialectSQL(dialect Dialect) string {
+	sql := make([]string, len(cond.children))
+	for i, child := range cond.children {
+		if dc, ok := child.(dialectCondition); ok {
+			sql[i] = dc.dialectSQL(dialect)
+		} else {
+			sql[i] = child.SQL()
+		}
 	}
+	return "(" + strings.Join(sql, " "+cond.op+" ") + ")"
 }
 
 // EscapeLike escapes a value to insert it in a LIKE query without unexpected wildcards.
@@ -191,8 +200,24 @@ func FilterIsNotNil(column string) Condition {
 // FilterLikeIgnoreCase filter rows with a LIKE match with wildcards in both sides
 // and ignoring the case of the values.
 func FilterLikeIgnoreCase(column, value string) Condition {
-	return &sqlCondition{
-		sql:    "LOWER(" + column + ") LIKE ?",
-		values: []interface{}{"%" + EscapeLike(value) + "%"},
+	return &likeIgnoreCaseCondition{
+		column: column,
+		value:  "%" + EscapeLike(value) + "%",
 	}
 }
+
+type likeIgnoreCaseCondition struct {
+	column, value string
+}
+
+func (cond *likeIgnoreCaseCondition) SQL() string {
+	return cond.dialectSQL(MySQL)
+}
+
+func (cond *likeIgnoreCaseCondition) Values() []interface{} {
+	return []interface{}{cond.value}
+}
+
+func (cond *likeIgnoreCaseCondition) dialectSQL(dialect Dialect) string {
+	return dialect.LikeIgnoreCaseSQL(cond.column)
+}
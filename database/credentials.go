@@ -4,7 +4,7 @@ import (
 	"fmt"
 )
 
-// Credentials configures the authentication and address of the remote MySQL database.
+// Credentials configures the authentication and address of the remote database.
 type Credentials struct {
 	User, Password     string
 	Address, Database  string
@@ -13,7 +13,7 @@ type Credentials struct {
 }
 
 // String returns the credentials with the exact format the Go MySQL driver needs
-// to connect to it.
+// to connect to it. Other dialects build their own connection string with Dialect.DSN.
 func (c Credentials) String() string {
 	protocol := c.Protocol
 	if protocol == "" {
@@ -5,21 +5,36 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/altipla-consulting/errors"
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
 	log "github.com/sirupsen/logrus"
 )
 
-// Database represents a reusable connection to a remote MySQL database.
+// Database represents a reusable connection to a remote database.
 type Database struct {
-	sess  *sql.DB
-	debug bool
+	sess         *sql.DB
+	debug        bool
+	maxOpenConns int
+	maxIdleConns int
+	replicas     []*replica
+	replicaCreds []Credentials
+	healthCheck  time.Duration
+	next         uint32
+	stop         chan struct{}
+	retry        RetryPolicy
+	dialect      Dialect
 }
 
-// Open starts a new connection to a remote MySQL database using the provided credentials
+// Open starts a new connection to a remote database using the provided credentials.
+// It connects to MySQL unless other dialect is configured with WithDialect.
 func Open(credentials Credentials, options ...Option) (*Database, error) {
-	db := new(Database)
+	db := &Database{
+		maxOpenConns: 3,
+		healthCheck:  DefaultReplicaHealthCheck,
+		dialect:      MySQL,
+	}
 	for _, option := range options {
 		option(db)
 	}
@@ -29,16 +44,20 @@ func Open(credentials Credentials, options ...Option) (*Database, error) {
 	}
 
 	var err error
-	db.sess, err = sql.Open("mysql", credentials.String())
+	db.sess, err = sql.Open(db.dialect.DriverName(), db.dialect.DSN(credentials))
 	if err != nil {
-		return nil, fmt.Errorf("cannot connect to mysql: %v", err)
+		return nil, fmt.Errorf("cannot connect to %s: %v", db.dialect.DriverName(), err)
 	}
 
-	db.sess.SetMaxOpenConns(3)
-	db.sess.SetMaxIdleConns(0)
+	db.sess.SetMaxOpenConns(db.maxOpenConns)
+	db.sess.SetMaxIdleConns(db.maxIdleConns)
 
 	if err := db.sess.Ping(); err != nil {
-		return nil, fmt.Errorf("cannot ping mysql: %v", err)
+		return nil, fmt.Errorf("cannot ping %s: %v", db.dialect.DriverName(), err)
+	}
+
+	if err := db.openReplicas(); err != nil {
+		return nil, errors.Trace(err)
 	}
 
 	return db, nil
@@ -57,6 +76,9 @@ func (db *Database) Collection(model Model, opts ...CollectionOption) *Collectio
 // Close the connection. You should not use a database after closing it, nor any
 // of its generated collections.
 func (db *Database) Close() error {
+	if err := db.closeReplicas(); err != nil {
+		return errors.Trace(err)
+	}
 	return errors.Trace(db.sess.Close())
 }
 
@@ -129,6 +151,10 @@ func (db *Database) Select(ctx context.Context, dest interface{}, query string,
 
 // SelectAll fetchs the full list of rows and loads a pointer to a slice with them.
 func (db *Database) SelectAll(ctx context.Context, dest interface{}, query string, params ...interface{}) error {
+	return errors.Trace(db.selectAll(ctx, db.executor(ctx), dest, query, params...))
+}
+
+func (db *Database) selectAll(ctx context.Context, exec executor, dest interface{}, query string, params ...interface{}) error {
 	v := reflect.ValueOf(dest)
 	t := reflect.TypeOf(dest)
 
@@ -147,7 +173,7 @@ func (db *Database) SelectAll(ctx context.Context, dest interface{}, query strin
 		return errors.Trace(err)
 	}
 
-	rows, err := db.executor(ctx).QueryContext(ctx, query, params...)
+	rows, err := exec.QueryContext(ctx, query, params...)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -191,7 +217,9 @@ func (db *Database) SelectAll(ctx context.Context, dest interface{}, query strin
 type key int
 
 const (
-	keyTx = key(1)
+	keyTx           = key(1)
+	keyForcePrimary = key(2)
+	keySavepoint    = key(3)
 )
 
 type executor interface {
@@ -203,36 +231,17 @@ type executor interface {
 func (db *Database) executor(ctx context.Context) executor {
 	tx, ok := ctx.Value(keyTx).(*sql.Tx)
 	if ok {
-		return tx
+		return db.wrap(tx)
 	}
-	return db.sess
+	return db.wrap(db.sess)
 }
 
-type TransactionalFn func(ctx context.Context) error
-
-func (db *Database) RunTransaction(ctx context.Context, fn TransactionalFn) error {
-	tx, err := db.sess.BeginTx(ctx, nil)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	ctx = context.WithValue(ctx, keyTx, tx)
-
-	if err := fn(ctx); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return fmt.Errorf("unable to rollback transaction: %w", err)
-		}
-
-		return errors.Trace(err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return errors.Trace(err)
+// wrap adapts the queries sent to the executor to the dialect of the database.
+func (db *Database) wrap(exec executor) executor {
+	if db.dialect == nil || db.dialect == MySQL {
+		return exec
 	}
-
-	return nil
+	return &rebindExecutor{exec: exec, dialect: db.dialect}
 }
 
 // Option can be passed when opening a new connection to a database.
@@ -244,3 +253,23 @@ func WithDebug(debug bool) Option {
 		db.debug = debug
 	}
 }
+
+// WithMaxOpenConns changes the maximum number of open connections to the primary
+// and to each of the replicas. By default it is 3.
+func WithMaxOpenConns(n int) Option {
+	if n < 1 {
+		panic("cannot configure less than 1 open connection")
+	}
+
+	return func(db *Database) {
+		db.maxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns changes the maximum number of idle connections kept open to
+// the primary and to each of the replicas. By default no idle connection is kept.
+func WithMaxIdleConns(n int) Option {
+	return func(db *Database) {
+		db.maxIdleConns = n
+	}
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// Dialect adapts the SQL generated by the package to a specific database engine.
+//
+// The package always writes its queries with "?" placeholders and backtick quoted
+// identifiers, like MySQL; the dialect rebinds them to the syntax of the engine
+// before running them. Raw queries passed to Exec, Select, Filter, ... can be
+// written the same way.
+type Dialect interface {
+	// DriverName returns the name of the database/sql driver. The driver should be
+	// imported by the application, only the MySQL one is imported by this package.
+	DriverName() string
+
+	// DSN returns the connection string of the credentials.
+	DSN(credentials Credentials) string
+
+	// Rebind adapts the placeholders and quoted identifiers of the query.
+	Rebind(query string) string
+
+	// UpsertSQL returns the clause appended to a multiple row INSERT to update the
+	// cols of the rows that already exist with the same primary keys.
+	UpsertSQL(pks, cols []string) string
+
+	// LikeIgnoreCaseSQL returns a condition to match the column against a LIKE
+	// pattern placeholder ignoring the case.
+	LikeIgnoreCaseSQL(column string) string
+
+	// ReturningSQL returns the clause appended to an INSERT to read the generated
+	// autoincrement column. If it returns an empty string LastInsertId will be used instead.
+	ReturningSQL(column string) string
+
+	// ForUpdateSQL returns the clause appended to a SELECT to lock the rows until
+	// the end of the transaction.
+	ForUpdateSQL() string
+
+	// TruncateSQL returns the statements that remove every row of a table and reset
+	// its autoincrement value.
+	TruncateSQL(table string) []string
+}
+
+var (
+	// MySQL is the default dialect of the package.
+	MySQL Dialect = MySQLDialect{}
+
+	// PostgreSQL uses the "postgres" driver registered by github.com/lib/pq.
+	PostgreSQL Dialect = PostgreSQLDialect{Driver: "postgres"}
+
+	// SQLite uses the "sqlite3" driver registered by github.com/mattn/go-sqlite3. It is
+	// mostly useful for fast local tests. The Database field of the credentials is
+	// the path of the file, or ":memory:".
+	SQLite Dialect = SQLiteDialect{Driver: "sqlite3"}
+)
+
+// WithDialect changes the database engine the connection will use. By default it is MySQL.
+func WithDialect(dialect Dialect) Option {
+	return func(db *Database) {
+		db.dialect = dialect
+	}
+}
+
+// MySQLDialect generates MySQL queries.
+type MySQLDialect struct{}
+
+func (d MySQLDialect) DriverName() string {
+	return "mysql"
+}
+
+func (d MySQLDialect) DSN(credentials Credentials) string {
+	return credentials.String()
+}
+
+func (d MySQLDialect) Rebind(query string) string {
+	return query
+}
+
+func (d MySQLDialect) UpsertSQL(pks, cols []string) string {
+	if len(cols) == 0 {
+		return ""
+	}
+
+	updates := make([]string, len(cols))
+	for i, col := range cols {
+		updates[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (d MySQLDialect) LikeIgnoreCaseSQL(column string) string {
+	return "LOWER(" + column + ") LIKE ?"
+}
+
+func (d MySQLDialect) ReturningSQL(column string) string {
+	return ""
+}
+
+func (d MySQLDialect) ForUpdateSQL() string {
+	return "FOR UPDATE"
+}
+
+func (d MySQLDialect) TruncateSQL(table string) []string {
+	return []string{
+		fmt.Sprintf(`DELETE FROM %s`, table),
+		fmt.Sprintf(`ALTER TABLE %s AUTO_INCREMENT = 1`, table),
+	}
+}
+
+// PostgreSQLDialect generates PostgreSQL queries.
+type PostgreSQLDialect struct {
+	// Driver is the name of the registered driver, e.g. "postgres" or "pgx".
+	Driver string
+
+	// SSLMode is sent in the connection string if present, e.g. "disable" or "require".
+	SSLMode string
+}
+
+func (d PostgreSQLDialect) DriverName() string {
+	return d.Driver
+}
+
+func (d PostgreSQLDialect) DSN(credentials Credentials) string {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(credentials.User, credentials.Password),
+		Host:   credentials.Address,
+		Path:   "/" + credentials.Database,
+	}
+	if d.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {d.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+func (d PostgreSQLDialect) Rebind(query string) string {
+	return rebind(query, true)
+}
+
+func (d PostgreSQLDialect) UpsertSQL(pks, cols []string) string {
+	return conflictUpsertSQL(pks, cols)
+}
+
+func (d PostgreSQLDialect) LikeIgnoreCaseSQL(column string) string {
+	return column + " ILIKE ?"
+}
+
+func (d PostgreSQLDialect) ReturningSQL(column string) string {
+	return "RETURNING " + column
+}
+
+func (d PostgreSQLDialect) ForUpdateSQL() string {
+	return "FOR UPDATE"
+}
+
+func (d PostgreSQLDialect) TruncateSQL(table string) []string {
+	return []string{
+		fmt.Sprintf(`TRUNCATE TABLE %s RESTART IDENTITY`, table),
+	}
+}
+
+// SQLiteDialect generates SQLite queries.
+type SQLiteDialect struct {
+	// Driver is the name of the registered driver, e.g. "sqlite3" or "sqlite".
+	Driver string
+}
+
+func (d SQLiteDialect) DriverName() string {
+	return d.Driver
+}
+
+func (d SQLiteDialect) DSN(credentials Credentials) string {
+	return credentials.Database
+}
+
+func (d SQLiteDialect) Rebind(query string) string {
+	return rebind(query, false)
+}
+
+func (d SQLiteDialect) UpsertSQL(pks, cols []string) string {
+	return conflictUpsertSQL(pks, cols)
+}
+
+func (d SQLiteDialect) LikeIgnoreCaseSQL(column string) string {
+	return "LOWER(" + column + ") LIKE ?"
+}
+
+func (d SQLiteDialect) ReturningSQL(column string) string {
+	return "RETURNING " + column
+}
+
+// ForUpdateSQL returns nothing because SQLite locks the whole database when
+// writing inside a transaction.
+func (d SQLiteDialect) ForUpdateSQL() string {
+	return ""
+}
+
+// TruncateSQL only deletes the rows, the autoincrement value is reset afterwards
+// by resetSequence.
+func (d SQLiteDialect) TruncateSQL(table string) []string {
+	return []string{
+		fmt.Sprintf(`DELETE FROM %s`, table),
+	}
+}
+
+// resetSequence removes the autoincrement value of the table. SQLite stores them in
+// the sqlite_sequence table, that does not exist until the first table with an
+// AUTOINCREMENT column is created. Tables without it restart from 1 after deleting
+// all their rows.
+func (d SQLiteDialect) resetSequence(ctx context.Context, exec executor, table string) error {
+	var n int64
+	row := exec.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'`)
+	if err := row.Scan(&n); err != nil {
+		return errors.Trace(err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if _, err := exec.ExecContext(ctx, `DELETE FROM sqlite_sequence WHERE name = ?`, table); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func conflictUpsertSQL(pks, cols []string) string {
+	if len(cols) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(pks, ", "))
+	}
+
+	updates := make([]string, len(cols))
+	for i, col := range cols {
+		updates[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(pks, ", "), strings.Join(updates, ", "))
+}
+
+// rebind replaces backticks with double quotes and, if numbered is true, the "?"
+// placeholders with "$1", "$2", ... Both are kept inside string literals.
+func rebind(query string, numbered bool) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	var n int
+	var literal bool
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			literal = !literal
+			b.WriteRune(r)
+		case literal:
+			b.WriteRune(r)
+		case r == '`':
+			b.WriteRune('"')
+		case r == '?' && numbered:
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sequenceResetter is implemented by dialects that need to inspect the database
+// to reset the autoincrement value of a table after truncating it.
+type sequenceResetter interface {
+	resetSequence(ctx context.Context, exec executor, table string) error
+}
+
+// dialectCondition is implemented by the conditions of the package that need
+// a different SQL in each dialect.
+type dialectCondition interface {
+	Condition
+	dialectSQL(dialect Dialect) string
+}
+
+type rebindExecutor struct {
+	exec    executor
+	dialect Dialect
+}
+
+func (e *rebindExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return e.exec.ExecContext(ctx, e.dialect.Rebind(query), args...)
+}
+
+func (e *rebindExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return e.exec.QueryContext(ctx, e.dialect.Rebind(query), args...)
+}
+
+func (e *rebindExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return e.exec.QueryRowContext(ctx, e.dialect.Rebind(query), args...)
+}
@@ -19,6 +19,10 @@ var (
 	// updated in the background by other process. This errors will prevent you from
 	// potentially overwriting those changes.
 	ErrConcurrentTransaction = errors.New("concurrent transaction")
+
+	// ErrMigrationsLocked is returned when another instance is running the
+	// migrations and the lock cannot be acquired in time.
+	ErrMigrationsLocked = errors.New("migrations locked by another instance")
 )
 
 // MultiError stores a list of error when retrieving multiple models and only
@@ -6,14 +6,21 @@ import (
 	"github.com/altipla-consulting/errors"
 )
 
+// HookFn is called with the model in the different steps of its lifecycle. The
+// context contains the transaction if the operation runs inside one. Returning
+// an error from a before hook aborts the operation.
 type HookFn func(ctx context.Context, instance Model) error
 
 type hooker struct {
-	afterPut []HookFn
+	beforePut    []HookFn
+	afterPut     []HookFn
+	beforeDelete []HookFn
+	afterDelete  []HookFn
+	afterGet     []HookFn
 }
 
-func (h *hooker) runAfterPut(ctx context.Context, instance Model) error {
-	for _, fn := range h.afterPut {
+func (h *hooker) run(ctx context.Context, hooks []HookFn, instance Model) error {
+	for _, fn := range hooks {
 		if err := fn(ctx, instance); err != nil {
 			return errors.Trace(err)
 		}
@@ -21,8 +28,60 @@ func (h *hooker) runAfterPut(ctx context.Context, instance Model) error {
 	return nil
 }
 
+func (h *hooker) runBeforePut(ctx context.Context, instance Model) error {
+	return errors.Trace(h.run(ctx, h.beforePut, instance))
+}
+
+func (h *hooker) runAfterPut(ctx context.Context, instance Model) error {
+	return errors.Trace(h.run(ctx, h.afterPut, instance))
+}
+
+func (h *hooker) runBeforeDelete(ctx context.Context, instance Model) error {
+	return errors.Trace(h.run(ctx, h.beforeDelete, instance))
+}
+
+func (h *hooker) runAfterDelete(ctx context.Context, instance Model) error {
+	return errors.Trace(h.run(ctx, h.afterDelete, instance))
+}
+
+func (h *hooker) runAfterGet(ctx context.Context, instance Model) error {
+	return errors.Trace(h.run(ctx, h.afterGet, instance))
+}
+
+// WithBeforePut runs the hook before a model is inserted or updated. If the hook
+// returns an error the model won't be stored.
+func WithBeforePut(fn HookFn) CollectionOption {
+	return func(c *Collection) {
+		c.h.beforePut = append(c.h.beforePut, fn)
+	}
+}
+
+// WithAfterPut runs the hook after a model is inserted or updated.
 func WithAfterPut(fn HookFn) CollectionOption {
 	return func(c *Collection) {
 		c.h.afterPut = append(c.h.afterPut, fn)
 	}
 }
+
+// WithBeforeDelete runs the hook before a model is deleted. If the hook returns
+// an error the model won't be deleted.
+func WithBeforeDelete(fn HookFn) CollectionOption {
+	return func(c *Collection) {
+		c.h.beforeDelete = append(c.h.beforeDelete, fn)
+	}
+}
+
+// WithAfterDelete runs the hook after a model is deleted.
+func WithAfterDelete(fn HookFn) CollectionOption {
+	return func(c *Collection) {
+		c.h.afterDelete = append(c.h.afterDelete, fn)
+	}
+}
+
+// WithAfterGet runs the hook after a model is retrieved from the database with
+// any of the read methods (Get, GetAll, GetMulti, First, Iterator, ...).
+func WithAfterGet(fn HookFn) CollectionOption {
+	return func(c *Collection) {
+		c.h.afterGet = append(c.h.afterGet, fn)
+	}
+}
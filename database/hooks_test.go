@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/altipla-consulting/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,3 +28,116 @@ func TestWithAfterPut(t *testing.T) {
 
 	require.Equal(t, called, 1)
 }
+
+func TestWithBeforePutAbortsWrite(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	fn := func(ctx context.Context, instance Model) error {
+		return errors.Errorf("invalid model")
+	}
+	c := testDB.Collection(new(testingModel), WithBeforePut(fn))
+
+	m := &testingModel{
+		Code: "foo",
+		Name: "bar",
+	}
+	require.EqualError(t, c.Put(ctx, m), "invalid model")
+
+	n, err := testings.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 0)
+}
+
+func TestWithBeforePutTransaction(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	fn := func(ctx context.Context, instance Model) error {
+		audit := &testingAutoModel{
+			Name: instance.(*testingModel).Code,
+		}
+		return errors.Trace(testingsAuto.Put(ctx, audit))
+	}
+	c := testDB.Collection(new(testingModel), WithBeforePut(fn))
+
+	err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, c.Put(ctx, &testingModel{Code: "foo"}))
+		return errors.Errorf("rollback")
+	})
+	require.EqualError(t, err, "rollback")
+
+	n, err := testingsAuto.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 0)
+}
+
+func TestWithBeforeDeleteAbortsWrite(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	fn := func(ctx context.Context, instance Model) error {
+		return errors.Errorf("cannot delete")
+	}
+	c := testDB.Collection(new(testingModel), WithBeforeDelete(fn))
+
+	m := &testingModel{
+		Code: "foo",
+	}
+	require.NoError(t, c.Put(ctx, m))
+	require.EqualError(t, c.Delete(ctx, m), "cannot delete")
+
+	n, err := testings.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 1)
+}
+
+func TestWithAfterDelete(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	var called int
+	fn := func(ctx context.Context, instance Model) error {
+		called++
+		return nil
+	}
+	c := testDB.Collection(new(testingModel), WithAfterDelete(fn))
+
+	models := []*testingModel{
+		{Code: "foo"},
+		{Code: "bar"},
+		{Code: "baz"},
+	}
+	require.NoError(t, c.PutMulti(ctx, models))
+	require.NoError(t, c.Delete(ctx, models[0]))
+	require.NoError(t, c.DeleteMulti(ctx, models[1:]))
+
+	require.Equal(t, called, 3)
+}
+
+func TestWithAfterGet(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	var called int
+	fn := func(ctx context.Context, instance Model) error {
+		called++
+		return nil
+	}
+	c := testDB.Collection(new(testingModel), WithAfterGet(fn))
+
+	require.NoError(t, c.Put(ctx, &testingModel{Code: "foo"}))
+	require.NoError(t, c.Put(ctx, &testingModel{Code: "bar"}))
+
+	require.NoError(t, c.Get(ctx, &testingModel{Code: "foo"}))
+	require.Equal(t, called, 1)
+
+	var models []*testingModel
+	require.NoError(t, c.GetAll(ctx, &models))
+	require.Equal(t, called, 3)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/altipla-consulting/errors"
@@ -8,8 +9,10 @@ import (
 
 // Iterator helps to loop through rows of a collection retrieving a single model each time.
 type Iterator struct {
+	ctx   context.Context
 	rows  *sql.Rows
 	props []*Property
+	h     *hooker
 }
 
 // Close finishes the iteration. Do not use the iterator after closing it.
@@ -49,5 +52,9 @@ func (it *Iterator) Next(model Model) error {
 
 	props = updateModelProps(it.props, model)
 
-	return model.Tracking().AfterGet(props)
+	if err := model.Tracking().AfterGet(props); err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(it.h.runAfterGet(it.ctx, model))
 }
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/altipla-consulting/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Join relates the rows of both collections with an INNER JOIN using the on SQL
+// statement. The joined collection must have an alias and its filters will be
+// added to the ON clause of the join. Refer to the columns of both tables using
+// their aliases to avoid ambiguous names:
+//
+//	orders := db.Collection(new(Order)).Alias("o")
+//	customers := db.Collection(new(Customer)).Alias("c").Filter("c.country =", "ES")
+//	orders.Join(customers, "o.customer = c.code")
+//
+// Joins only apply to the read operations of the collection. The columns of the
+// joined table can be read with Project.
+func (c *Collection) Join(other *Collection, on string) *Collection {
+	return c.join("INNER JOIN", other, on)
+}
+
+// LeftJoin relates the rows of both collections with a LEFT JOIN using the on SQL
+// statement. It works like Join, but rows without a match in the joined
+// collection will be kept with NULL values in its columns.
+func (c *Collection) LeftJoin(other *Collection, on string) *Collection {
+	return c.join("LEFT JOIN", other, on)
+}
+
+func (c *Collection) join(kind string, other *Collection, on string) *Collection {
+	if on == "" {
+		panic("join SQL statement is required to join collections")
+	}
+	if other.alias == "" {
+		panic("joined collections should have an alias")
+	}
+
+	joins := make([]*sqlJoin, len(c.joins), len(c.joins)+1)
+	copy(joins, c.joins)
+	c.joins = append(joins, &sqlJoin{
+		kind:       kind,
+		table:      other.model.TableName(),
+		alias:      other.alias,
+		on:         on,
+		conditions: other.queryConditions(),
+	})
+	return c
+}
+
+// GroupBy groups the rows by the columns or SQL expressions. It should be used
+// with Project and aggregate projections like Sum or CountDistinct.
+func (c *Collection) GroupBy(columns ...string) *Collection {
+	c.groupBy = append(c.groupBy, columns...)
+	return c
+}
+
+// Having applies a new simple filter to the groups of the collection. It accepts
+// the same kind of SQL than Filter, usually with aggregates:
+//
+//	Having("SUM(o.total) >", 100)
+func (c *Collection) Having(sqlStmt string, value interface{}) *Collection {
+	return c.HavingCond(Filter(sqlStmt, value))
+}
+
+// HavingCond applies a generic condition to the groups of the collection.
+func (c *Collection) HavingCond(condition Condition) *Collection {
+	c.having = append(c.having, c.resolveCondition(condition))
+	return c
+}
+
+// Projection is a column or SQL expression that Project can select into the
+// field of a struct.
+type Projection interface {
+	// SQL returns the expression that will be selected, including the name of the
+	// result column.
+	SQL() string
+}
+
+type sqlProjection struct {
+	sql string
+}
+
+func (proj *sqlProjection) SQL() string {
+	return proj.sql
+}
+
+func newProjection(expr, name string) Projection {
+	return &sqlProjection{fmt.Sprintf("%s AS `%s`", expr, name)}
+}
+
+// Column selects a column or SQL expression with the name of the struct field
+// it should be stored in.
+func Column(expr, name string) Projection {
+	return newProjection(expr, name)
+}
+
+// Count counts the rows of each group.
+func Count(name string) Projection {
+	return newProjection("COUNT(*)", name)
+}
+
+// CountDistinct counts the distinct values of the expression in each group.
+func CountDistinct(expr, name string) Projection {
+	return newProjection(fmt.Sprintf("COUNT(DISTINCT %s)", expr), name)
+}
+
+// Sum adds the values of the expression in each group.
+func Sum(expr, name string) Projection {
+	return newProjection(fmt.Sprintf("SUM(%s)", expr), name)
+}
+
+// Avg averages the values of the expression in each group.
+func Avg(expr, name string) Projection {
+	return newProjection(fmt.Sprintf("AVG(%s)", expr), name)
+}
+
+// Max returns the maximum value of the expression in each group.
+func Max(expr, name string) Projection {
+	return newProjection(fmt.Sprintf("MAX(%s)", expr), name)
+}
+
+// Min returns the minimum value of the expression in each group.
+func Min(expr, name string) Projection {
+	return newProjection(fmt.Sprintf("MIN(%s)", expr), name)
+}
+
+// Project selects the projections and loads the results in dest, a pointer to
+// a slice of pointers to arbitrary structs. Each struct field will receive the
+// column with the same name as its db tag, or the field name if the tag is not
+// present. If no projection is passed it will select the columns of the struct
+// from the table of the collection.
+//
+//	type report struct {
+//	  Customer string `db:"customer"`
+//	  Total    int64  `db:"total"`
+//	}
+//	var rows []*report
+//	err := orders.Join(customers, "o.customer = c.code").
+//	  GroupBy("c.code").
+//	  Project(ctx, &rows, Column("c.code", "customer"), Sum("o.total", "total"))
+func (c *Collection) Project(ctx context.Context, dest interface{}, projections ...Projection) error {
+	props, err := destGenericProps(dest)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	b := c.readBuilder(nil)
+	b.limit = c.limit
+	b.offset = c.offset
+	b.orders = c.orders
+
+	var cols []string
+	for _, proj := range projections {
+		cols = append(cols, proj.SQL())
+	}
+	if len(cols) == 0 {
+		for _, prop := range props {
+			cols = append(cols, b.qualify(prop.Name))
+		}
+	}
+
+	statement, values := b.SelectSQLCols(cols...)
+	if c.db.debug {
+		log.Println("database [Project]:", statement)
+	}
+
+	return errors.Trace(c.db.selectAll(ctx, c.db.reader(ctx), dest, statement, values...))
+}
+
+func destGenericProps(dest interface{}) ([]*Property, error) {
+	t := reflect.TypeOf(dest)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return nil, errors.Errorf("pass a pointer to a slice to Project")
+	}
+	if t.Elem().Elem().Kind() != reflect.Ptr || t.Elem().Elem().Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("pass a slice of pointers to structs to Project")
+	}
+
+	return extractGenericProps(reflect.New(t.Elem().Elem().Elem()).Interface())
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinSQL(t *testing.T) {
+	children := newCollection(nil, new(testingRelChild)).Alias("c").Filter("c.foo =", "foo-value")
+	c := newCollection(nil, new(testingRelParent)).Alias("p").LeftJoin(children, "p.id = c.parent").Filter("p.id >", 3)
+
+	stmt, values := c.readBuilder(c.props).SelectSQL()
+	require.Equal(t, stmt, "SELECT p.`revision`, p.`id` FROM testing_relparent AS p LEFT JOIN testing_relchild AS c ON (p.id = c.parent) AND c.foo = ? WHERE p.id > ?")
+	require.Equal(t, values, []interface{}{"foo-value", 3})
+}
+
+func TestQualifyColumns(t *testing.T) {
+	parents := newCollection(nil, new(testingRelParent))
+	require.Equal(t, parents.readBuilder(nil).qualify("`id`"), "`id`")
+
+	children := newCollection(nil, new(testingRelChild)).Alias("c")
+	joined := newCollection(nil, new(testingRelParent)).Join(children, "testing_relparent.id = c.parent")
+	require.Equal(t, joined.readBuilder(nil).qualify("`id`"), "testing_relparent.`id`")
+
+	joined = newCollection(nil, new(testingRelParent)).Alias("p").Join(children, "p.id = c.parent")
+	require.Equal(t, joined.readBuilder(nil).qualify("`id`"), "p.`id`")
+}
+
+func TestGroupBySQL(t *testing.T) {
+	c := newCollection(nil, new(testingRelChild)).GroupBy("parent").Having("SUM(id) >", 10)
+
+	stmt, values := c.readBuilder(nil).SelectSQLCols(Column("parent", "parent").SQL(), Sum("id", "total").SQL())
+	require.Equal(t, stmt, "SELECT parent AS `parent`, SUM(id) AS `total` FROM testing_relchild GROUP BY parent HAVING SUM(id) > ?")
+	require.Equal(t, values, []interface{}{10})
+}
+
+func TestJoinWithoutAliasPanics(t *testing.T) {
+	require.PanicsWithValue(t, "joined collections should have an alias", func() {
+		newCollection(nil, new(testingRelParent)).Join(newCollection(nil, new(testingRelChild)), "id = parent")
+	})
+}
+
+type testingChildReport struct {
+	Parent   int64 `db:"parent"`
+	Children int64 `db:"children"`
+	Foos     int64 `db:"foos"`
+	MaxID    int64 `db:"max_id"`
+}
+
+func initRelations(t *testing.T) {
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, testingsRelParent.Put(ctx, new(testingRelParent)))
+	}
+	require.NoError(t, testingsRelChild.PutMulti(ctx, []*testingRelChild{
+		{Parent: 1, Foo: "foo"},
+		{Parent: 1, Foo: "foo"},
+		{Parent: 1, Foo: "bar"},
+		{Parent: 2, Foo: "foo"},
+	}))
+}
+
+func TestJoin(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	initRelations(t)
+
+	var models []*testingRelParent
+	children := testingsRelChild.Clone().Alias("c").Filter("c.foo =", "bar")
+	require.NoError(t, testingsRelParent.Clone().Alias("p").Join(children, "p.id = c.parent").GetAll(ctx, &models))
+	require.Len(t, models, 1)
+	require.EqualValues(t, models[0].ID, 1)
+}
+
+func TestProjectGroupBy(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	initRelations(t)
+
+	var rows []*testingChildReport
+	q := testingsRelParent.Clone().Alias("p").
+		LeftJoin(testingsRelChild.Clone().Alias("c"), "p.id = c.parent").
+		GroupBy("p.id").
+		Order("p.id")
+	err := q.Project(ctx, &rows,
+		Column("p.id", "parent"),
+		Count("children"),
+		CountDistinct("c.foo", "foos"),
+		Column("COALESCE(MAX(c.id), 0)", "max_id"))
+	require.NoError(t, err)
+
+	require.Len(t, rows, 3)
+	require.Equal(t, rows[0], &testingChildReport{Parent: 1, Children: 3, Foos: 2, MaxID: 3})
+	require.Equal(t, rows[1], &testingChildReport{Parent: 2, Children: 1, Foos: 1, MaxID: 4})
+	require.Equal(t, rows[2], &testingChildReport{Parent: 3, Children: 1, Foos: 0, MaxID: 0})
+
+	n, err := q.Clone().Having("COUNT(c.id) >", 0).Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 2)
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// Cursor contains the values of the order columns of a row. It can be used to
+// continue reading a collection from that row with StartAfter.
+type Cursor []interface{}
+
+type keysetColumn struct {
+	name string
+	desc bool
+}
+
+func (col keysetColumn) orderSQL() string {
+	if col.desc {
+		return quoteColumn(col.name) + " DESC"
+	}
+	return quoteColumn(col.name) + " ASC"
+}
+
+// column returns the name of the column without the table alias.
+func (col keysetColumn) column() string {
+	if i := strings.LastIndex(col.name, "."); i >= 0 {
+		return col.name[i+1:]
+	}
+	return col.name
+}
+
+// quoteColumn escapes the column name, keeping the table alias outside the
+// quotes if present.
+func quoteColumn(name string) string {
+	if parts := strings.SplitN(name, ".", 2); len(parts) == 2 {
+		return fmt.Sprintf("%s.`%s`", parts[0], parts[1])
+	}
+	return fmt.Sprintf("`%s`", name)
+}
+
+// keysetColumns returns the order columns of the collection followed by the
+// primary keys that are not already ordered to have a stable order between rows.
+func (c *Collection) keysetColumns() []keysetColumn {
+	if c.customOrder {
+		panic("cannot use keyset pagination in a collection ordered with a custom sorter")
+	}
+
+	cols := make([]keysetColumn, len(c.keyset))
+	copy(cols, c.keyset)
+	for _, prop := range c.props {
+		if !prop.PrimaryKey {
+			continue
+		}
+
+		var found bool
+		for _, col := range c.keyset {
+			if col.column() == prop.UnescapedName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			cols = append(cols, keysetColumn{name: prop.UnescapedName})
+		}
+	}
+
+	return cols
+}
+
+func (c *Collection) setKeyset(cols []keysetColumn) {
+	c.keyset = cols
+	c.orders = make([]string, len(cols))
+	for i, col := range cols {
+		c.orders[i] = col.orderSQL()
+	}
+}
+
+// Reverse inverts the direction of all the orders of the collection. It cannot
+// be used in collections ordered with OrderSorter.
+func (c *Collection) Reverse() *Collection {
+	if c.customOrder {
+		panic("cannot reverse a collection ordered with a custom sorter")
+	}
+
+	cols := make([]keysetColumn, len(c.keyset))
+	for i, col := range c.keyset {
+		cols[i] = keysetColumn{name: col.name, desc: !col.desc}
+	}
+	c.setKeyset(cols)
+
+	return c
+}
+
+// StartAfter filters the rows that come after the cursor in the order of the
+// collection. It is the base of the keyset pagination: instead of skipping rows
+// with an offset it continues from the last row read.
+//
+// The primary keys are added at the end of the order if they are not present to
+// have a stable order between rows with the same values. A nil cursor will only
+// add those orders and can be used to read the first page.
+//
+// Nullable columns are not supported in the order of the collection when using
+// this method, NULL values cannot be compared with the cursor.
+func (c *Collection) StartAfter(cursor Cursor) *Collection {
+	cols := c.keysetColumns()
+	c.setKeyset(cols)
+
+	if cursor == nil {
+		return c
+	}
+	if len(cursor) != len(cols) {
+		panic(fmt.Sprintf("cursor has %d values, expected %d", len(cursor), len(cols)))
+	}
+
+	return c.FilterCond(c.keysetCondition(cols, cursor))
+}
+
+func (c *Collection) keysetCondition(cols []keysetColumn, cursor Cursor) Condition {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = quoteColumn(col.name)
+		if c.alias != "" && !strings.Contains(col.name, ".") {
+			names[i] = c.alias + "." + names[i]
+		}
+	}
+
+	// The row comparison can use the indexes when all the columns share the same direction.
+	sameDirection := true
+	for _, col := range cols {
+		if col.desc != cols[0].desc {
+			sameDirection = false
+			break
+		}
+	}
+	if sameDirection {
+		op := ">"
+		if cols[0].desc {
+			op = "<"
+		}
+		placeholders := make([]string, len(cols))
+		for i := range placeholders {
+			placeholders[i] = "?"
+		}
+		return &sqlCondition{
+			sql:    fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ", "), op, strings.Join(placeholders, ", ")),
+			values: cursor,
+		}
+	}
+
+	// Mixed directions need the expanded form: (a > ?) OR (a = ? AND b < ?) OR ...
+	var ors []string
+	var values []interface{}
+	for i, col := range cols {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, names[j]+" = ?")
+			values = append(values, cursor[j])
+		}
+		op := ">"
+		if col.desc {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s ?", names[i], op))
+		values = append(values, cursor[i])
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return &sqlCondition{
+		sql:    "(" + strings.Join(ors, " OR ") + ")",
+		values: values,
+	}
+}
+
+// Cursor returns the values of the instance for the order columns of the collection
+// and its primary keys, in the same order StartAfter expects them. It returns an
+// error if any of the columns is NULL.
+func (c *Collection) Cursor(instance Model) (Cursor, error) {
+	props, err := extractModelProps(instance)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var cursor Cursor
+	for _, col := range c.keysetColumns() {
+		var prop *Property
+		for _, p := range props {
+			if p.UnescapedName == col.column() {
+				prop = p
+				break
+			}
+		}
+		if prop == nil {
+			return nil, errors.Errorf("order column %s not found in the model", col.name)
+		}
+
+		value, err := driver.DefaultParameterConverter.ConvertValue(prop.Value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if value == nil {
+			return nil, errors.Errorf("order column %s is nullable and its NULL value cannot be used in a cursor", col.name)
+		}
+		cursor = append(cursor, value)
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartAfterAddsPrimaryKeys(t *testing.T) {
+	c := newCollection(nil, new(testingModel)).Order("-name").StartAfter(nil)
+	require.Equal(t, c.orders, []string{"`name` DESC", "`code` ASC"})
+	require.Empty(t, c.conditions)
+}
+
+func TestStartAfterSameDirection(t *testing.T) {
+	c := newCollection(nil, new(testingModel)).Order("name").StartAfter(Cursor{"foo", "bar"})
+	require.Len(t, c.conditions, 1)
+	require.Equal(t, c.conditions[0].SQL(), "(`name`, `code`) > (?, ?)")
+	require.Equal(t, c.conditions[0].Values(), []interface{}{"foo", "bar"})
+}
+
+func TestStartAfterMixedDirections(t *testing.T) {
+	c := newCollection(nil, new(testingModel)).Alias("t").Order("-name").StartAfter(Cursor{"foo", "bar"})
+	require.Len(t, c.conditions, 1)
+	require.Equal(t, c.conditions[0].SQL(), "((t.`name` < ?) OR (t.`name` = ? AND t.`code` > ?))")
+	require.Equal(t, c.conditions[0].Values(), []interface{}{"foo", "foo", "bar"})
+}
+
+func TestReverse(t *testing.T) {
+	c := newCollection(nil, new(testingModel)).Order("-name").Order("code").Reverse()
+	require.Equal(t, c.orders, []string{"`name` ASC", "`code` DESC"})
+}
+
+type testingSorter struct{}
+
+func (sorter *testingSorter) SQL() string {
+	return "RAND()"
+}
+
+func TestReverseCustomSorterPanics(t *testing.T) {
+	require.PanicsWithValue(t, "cannot reverse a collection ordered with a custom sorter", func() {
+		newCollection(nil, new(testingModel)).OrderSorter(&testingSorter{}).Reverse()
+	})
+}
+
+func TestCursor(t *testing.T) {
+	c := newCollection(nil, new(testingModel)).Order("-name")
+	cursor, err := c.Cursor(&testingModel{Code: "foo", Name: "bar"})
+	require.NoError(t, err)
+	require.Equal(t, cursor, Cursor{"bar", "foo"})
+}
+
+type testingNullableModel struct {
+	ModelTracking
+
+	Code string         `db:"code,pk"`
+	Name sql.NullString `db:"name"`
+}
+
+func (model *testingNullableModel) TableName() string {
+	return "testing"
+}
+
+func TestCursorNullableColumn(t *testing.T) {
+	c := newCollection(nil, new(testingNullableModel)).Order("name")
+
+	cursor, err := c.Cursor(&testingNullableModel{Code: "foo", Name: sql.NullString{String: "bar", Valid: true}})
+	require.NoError(t, err)
+	require.Equal(t, cursor, Cursor{"bar", "foo"})
+
+	_, err = c.Cursor(&testingNullableModel{Code: "foo"})
+	require.EqualError(t, err, "order column name is nullable and its NULL value cannot be used in a cursor")
+}
+
+func TestStartAfterReadsNextRows(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, testings.PutMulti(ctx, []*testingModel{
+		{Code: "a", Name: "same"},
+		{Code: "b", Name: "same"},
+		{Code: "c", Name: "other"},
+	}))
+
+	var models []*testingModel
+	require.NoError(t, testings.Clone().Order("-name").StartAfter(Cursor{"same", "a"}).GetAll(ctx, &models))
+	require.Len(t, models, 2)
+	require.Equal(t, models[0].Code, "b")
+	require.Equal(t, models[1].Code, "c")
+}
@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMigrationsTable is the name of the table that tracks the applied
+// migrations if no other one is configured.
+const DefaultMigrationsTable = "schema_migrations"
+
+var migrationFilename = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema with the SQL needed to apply it
+// and, optionally, the SQL needed to revert it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations reads the migrations stored in the root of the filesystem. Files
+// should be named "<version>_<name>.up.sql" and "<version>_<name>.down.sql", for
+// example "0001_create_users.up.sql". The down file is optional. Any other file
+// that does not end in ".sql" will be ignored.
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	versions := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		parts := migrationFilename.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, errors.Errorf("invalid migration filename: %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid migration version %q: %v", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		migration, ok := versions[version]
+		if !ok {
+			migration = &Migration{
+				Version: version,
+				Name:    parts[2],
+			}
+			versions[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, errors.Errorf("migration version %d has two different names: %s and %s", version, migration.Name, parts[2])
+		}
+
+		switch parts[3] {
+		case "up":
+			migration.Up = string(content)
+		case "down":
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []*Migration
+	for _, migration := range versions {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, errors.Errorf("migration version %d does not have an up file", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrationStatus reports the state of a single migration in the database.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Missing is true if the migration was applied to the database but it is not
+	// available anymore in the migrations filesystem.
+	Missing bool
+}
+
+// MigratorOption configures a migrator.
+type MigratorOption func(m *Migrator)
+
+// WithMigrationsTable changes the name of the table that tracks the applied migrations.
+func WithMigrationsTable(table string) MigratorOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithMigrationsLockTimeout changes how much time we will wait for other instances
+// to finish their migrations before returning ErrMigrationsLocked. By default
+// it waits 30 seconds.
+func WithMigrationsLockTimeout(timeout time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.lockTimeout = timeout
+	}
+}
+
+// WithDryRun writes the SQL statements to w instead of running them. The tracking
+// table and the lock will still be read to know which migrations are pending.
+func WithDryRun(w io.Writer) MigratorOption {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+// Migrator applies and reverts migrations in a database.
+type Migrator struct {
+	db          *Database
+	fsys        fs.FS
+	table       string
+	lockTimeout time.Duration
+	dryRun      io.Writer
+}
+
+// Migrator prepares a new migrator reading the migrations from the filesystem.
+// It won't read them or make any query until one of its methods is called.
+func (db *Database) Migrator(fsys fs.FS, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		db:          db,
+		fsys:        fsys,
+		table:       DefaultMigrationsTable,
+		lockTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Up applies all the pending migrations in order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return errors.Trace(m.UpTo(ctx, -1))
+}
+
+// UpTo applies the pending migrations in order until the version is reached,
+// including the version itself. Pass -1 to apply all of them.
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	return m.run(ctx, func(ctx context.Context, conn *sql.Conn, migrations []*Migration, applied map[int64]*MigrationStatus) error {
+		for _, migration := range migrations {
+			if version >= 0 && migration.Version > version {
+				break
+			}
+			if applied[migration.Version] != nil {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, migration.Up); err != nil {
+				return errors.Trace(err)
+			}
+
+			record := fmt.Sprintf("INSERT INTO `%s`(version, name, applied_at) VALUES(?, ?, ?)", m.table)
+			if err := m.exec(ctx, conn, record, migration.Version, migration.Name, time.Now().UTC()); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.run(ctx, func(ctx context.Context, conn *sql.Conn, migrations []*Migration, applied map[int64]*MigrationStatus) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if applied[migrations[i].Version] != nil {
+				return errors.Trace(m.revert(ctx, conn, migrations[i]))
+			}
+		}
+		return nil
+	})
+}
+
+// DownTo reverts in reverse order all the applied migrations with a version
+// greater than the provided one. The version itself won't be reverted.
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	return m.run(ctx, func(ctx context.Context, conn *sql.Conn, migrations []*Migration, applied map[int64]*MigrationStatus) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if migrations[i].Version <= version {
+				break
+			}
+			if applied[migrations[i].Version] == nil {
+				continue
+			}
+
+			if err := m.revert(ctx, conn, migrations[i]); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	})
+}
+
+// Status returns the state of every known migration, the ones in the filesystem
+// and the ones applied in the database, sorted by version.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var result []*MigrationStatus
+	err := m.run(ctx, func(ctx context.Context, conn *sql.Conn, migrations []*Migration, applied map[int64]*MigrationStatus) error {
+		known := make(map[int64]bool)
+		for _, migration := range migrations {
+			known[migration.Version] = true
+
+			if status := applied[migration.Version]; status != nil {
+				result = append(result, status)
+				continue
+			}
+			result = append(result, &MigrationStatus{
+				Version: migration.Version,
+				Name:    migration.Name,
+			})
+		}
+
+		for version, status := range applied {
+			if !known[version] {
+				status.Missing = true
+				result = append(result, status)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+type migratorFn func(ctx context.Context, conn *sql.Conn, migrations []*Migration, applied map[int64]*MigrationStatus) error
+
+func (m *Migrator) run(ctx context.Context, fn migratorFn) error {
+	if m.db.dialect != MySQL {
+		return errors.Errorf("migrations are only supported in MySQL databases")
+	}
+
+	migrations, err := LoadMigrations(m.fsys)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// Locks in MySQL belong to the connection that acquires them, so we need
+	// to run everything in the same one.
+	conn, err := m.db.sess.Conn(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+
+	if err := m.lock(ctx, conn); err != nil {
+		return errors.Trace(err)
+	}
+	defer m.unlock(conn)
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(fn(ctx, conn, migrations, applied))
+}
+
+func (m *Migrator) lockName() string {
+	return "migrations." + m.table
+}
+
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	var acquired sql.NullInt64
+	timeout := int64(m.lockTimeout / time.Second)
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)`, m.lockName(), timeout).Scan(&acquired); err != nil {
+		return errors.Trace(err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return errors.Trace(ErrMigrationsLocked)
+	}
+
+	return nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	// Use a new context to release the lock even if the original one was cancelled.
+	if _, err := conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))`, m.lockName()); err != nil {
+		log.WithField("error", err.Error()).Error("Cannot release the migrations lock")
+	}
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]*MigrationStatus, error) {
+	var exists int64
+	q := `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	if err := conn.QueryRowContext(ctx, q, m.table).Scan(&exists); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	applied := make(map[int64]*MigrationStatus)
+	if exists == 0 {
+		create := fmt.Sprintf("CREATE TABLE `%s` (version BIGINT NOT NULL, name VARCHAR(191) NOT NULL, applied_at DATETIME NOT NULL, PRIMARY KEY(version))", m.table)
+		if err := m.exec(ctx, conn, create); err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, name, applied_at FROM `%s`", m.table))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		status := &MigrationStatus{Applied: true}
+		if err := rows.Scan(&status.Version, &status.Name, &status.AppliedAt); err != nil {
+			return nil, errors.Trace(err)
+		}
+		applied[status.Version] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	if strings.TrimSpace(migration.Down) == "" {
+		return errors.Errorf("migration version %d cannot be reverted, it does not have a down file", migration.Version)
+	}
+
+	if err := m.apply(ctx, conn, migration, migration.Down); err != nil {
+		return errors.Trace(err)
+	}
+
+	record := fmt.Sprintf("DELETE FROM `%s` WHERE version = ?", m.table)
+	return errors.Trace(m.exec(ctx, conn, record, migration.Version))
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration, content string) error {
+	if m.db.debug {
+		log.WithFields(log.Fields{
+			"version": migration.Version,
+			"name":    migration.Name,
+		}).Debug("Run migration")
+	}
+
+	for _, statement := range splitStatements(content) {
+		if err := m.exec(ctx, conn, statement); err != nil {
+			return fmt.Errorf("migration version %d failed: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, query string, params ...interface{}) error {
+	if m.dryRun != nil {
+		if len(params) > 0 {
+			_, err := fmt.Fprintf(m.dryRun, "%s; -- %v\n", query, params)
+			return errors.Trace(err)
+		}
+		_, err := fmt.Fprintf(m.dryRun, "%s;\n", query)
+		return errors.Trace(err)
+	}
+
+	if m.db.debug {
+		log.WithFields(log.Fields{
+			"query":  query,
+			"params": fmt.Sprintf("%#v", params),
+		}).Debug("Exec migration query")
+	}
+
+	_, err := conn.ExecContext(ctx, query, params...)
+	return errors.Trace(err)
+}
+
+// splitStatements separates the SQL statements of a migration file. The MySQL
+// driver cannot run multiple statements in a single call. It respects quoted
+// strings and comments that may contain semicolons.
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			current.WriteByte(ch)
+			for i++; i < len(content); i++ {
+				current.WriteByte(content[i])
+				if content[i] == '\\' && ch != '`' && i+1 < len(content) {
+					i++
+					current.WriteByte(content[i])
+					continue
+				}
+				if content[i] == ch {
+					break
+				}
+			}
+
+		case ch == '#' || (ch == '-' && strings.HasPrefix(content[i:], "-- ")):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+
+		case ch == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end == -1 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+
+		case ch == ';':
+			flush()
+
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	flush()
+
+	return statements
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_foo.up.sql":   {Data: []byte("CREATE TABLE migrations_foo (id INT(11) NOT NULL, PRIMARY KEY(id));")},
+	"0001_create_foo.down.sql": {Data: []byte("DROP TABLE migrations_foo;")},
+	"0002_insert_foo.up.sql":   {Data: []byte("INSERT INTO migrations_foo(id) VALUES (1);\nINSERT INTO migrations_foo(id) VALUES (2);")},
+	"0002_insert_foo.down.sql": {Data: []byte("DELETE FROM migrations_foo;")},
+	"README.md":                {Data: []byte("ignored")},
+}
+
+func initMigrations(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS migrations_foo`))
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS schema_migrations`))
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrations)
+	require.NoError(t, err)
+
+	require.Len(t, migrations, 2)
+	require.EqualValues(t, migrations[0].Version, 1)
+	require.Equal(t, migrations[0].Name, "create_foo")
+	require.Equal(t, migrations[0].Down, "DROP TABLE migrations_foo;")
+	require.EqualValues(t, migrations[1].Version, 2)
+	require.Equal(t, migrations[1].Name, "insert_foo")
+}
+
+func TestLoadMigrationsWithoutUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_create_foo.down.sql": {Data: []byte("DROP TABLE foo;")},
+	}
+	_, err := LoadMigrations(fsys)
+	require.EqualError(t, err, "migration version 1 does not have an up file")
+}
+
+func TestLoadMigrationsInvalidName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"create_foo.sql": {Data: []byte("CREATE TABLE foo;")},
+	}
+	_, err := LoadMigrations(fsys)
+	require.EqualError(t, err, "invalid migration filename: create_foo.sql")
+}
+
+func TestSplitStatements(t *testing.T) {
+	content := `
+    -- Comment with a semicolon;
+    CREATE TABLE foo (id INT(11));
+    /* Block; comment */
+    INSERT INTO foo(name) VALUES ('a;b'), ("c\";d");
+    # Another comment;
+    UPDATE foo SET name = 'x'
+  `
+	require.Equal(t, splitStatements(content), []string{
+		"CREATE TABLE foo (id INT(11))",
+		`INSERT INTO foo(name) VALUES ('a;b'), ("c\";d")`,
+		"UPDATE foo SET name = 'x'",
+	})
+}
+
+func TestMigratorUp(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	initMigrations(t)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Migrator(testMigrations).Up(ctx))
+
+	var n int64
+	require.NoError(t, testDB.QueryRow(ctx, `SELECT COUNT(*) FROM migrations_foo`).Scan(&n))
+	require.EqualValues(t, n, 2)
+
+	// Applying them again should not fail.
+	require.NoError(t, testDB.Migrator(testMigrations).Up(ctx))
+}
+
+func TestMigratorDown(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	initMigrations(t)
+	ctx := context.Background()
+
+	m := testDB.Migrator(testMigrations)
+	require.NoError(t, m.Up(ctx))
+	require.NoError(t, m.Down(ctx))
+
+	var n int64
+	require.NoError(t, testDB.QueryRow(ctx, `SELECT COUNT(*) FROM migrations_foo`).Scan(&n))
+	require.EqualValues(t, n, 0)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	require.True(t, status[0].Applied)
+	require.False(t, status[1].Applied)
+}
+
+func TestMigratorDownTo(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	initMigrations(t)
+	ctx := context.Background()
+
+	m := testDB.Migrator(testMigrations)
+	require.NoError(t, m.Up(ctx))
+	require.NoError(t, m.DownTo(ctx, 0))
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	require.False(t, status[0].Applied)
+	require.False(t, status[1].Applied)
+}
+
+func TestMigratorStatus(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	initMigrations(t)
+	ctx := context.Background()
+
+	m := testDB.Migrator(testMigrations)
+	require.NoError(t, m.UpTo(ctx, 1))
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	require.EqualValues(t, status[0].Version, 1)
+	require.True(t, status[0].Applied)
+	require.False(t, status[0].AppliedAt.IsZero())
+	require.EqualValues(t, status[1].Version, 2)
+	require.False(t, status[1].Applied)
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	initMigrations(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	require.NoError(t, testDB.Migrator(testMigrations, WithDryRun(&buf)).Up(ctx))
+	require.Contains(t, buf.String(), "CREATE TABLE migrations_foo")
+	require.Contains(t, buf.String(), "INSERT INTO migrations_foo(id) VALUES (2);")
+
+	var n int64
+	require.NoError(t, testDB.QueryRow(ctx, `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'migrations_foo'`).Scan(&n))
+	require.EqualValues(t, n, 0)
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultReplicaHealthCheck is the interval between pings to the read replicas
+// if no other is configured.
+const DefaultReplicaHealthCheck = 10 * time.Second
+
+type replica struct {
+	address string
+	sess    *sql.DB
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := r.sess.PingContext(ctx); err != nil {
+		if atomic.SwapInt32(&r.healthy, 0) == 1 {
+			log.WithFields(log.Fields{
+				"address": r.address,
+				"error":   err.Error(),
+			}).Warning("Database read replica is not healthy")
+		}
+		return
+	}
+	atomic.StoreInt32(&r.healthy, 1)
+}
+
+// WithReplicas registers read replicas of the primary database. Get, GetAll,
+// GetMulti, First, Iterator, Count and Project will read from a healthy replica
+// when they run outside a transaction. Writes and every query inside a transaction
+// will always go to the primary. If no replica is healthy reads will go to the
+// primary too.
+//
+// Replicas may lag behind the primary, use ForcePrimary in the reads that need
+// to see the latest changes.
+func WithReplicas(replicas ...Credentials) Option {
+	return func(db *Database) {
+		db.replicaCreds = append(db.replicaCreds, replicas...)
+	}
+}
+
+// WithReplicaHealthCheck changes the interval between pings to the read replicas
+// to check if they are healthy.
+func WithReplicaHealthCheck(interval time.Duration) Option {
+	if interval <= 0 {
+		panic("cannot configure a health check interval less than or equal to 0")
+	}
+
+	return func(db *Database) {
+		db.healthCheck = interval
+	}
+}
+
+// ForcePrimary returns a context that sends all the reads to the primary database
+// even if there are healthy replicas.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyForcePrimary, true)
+}
+
+func (db *Database) openReplicas() error {
+	if len(db.replicaCreds) == 0 {
+		return nil
+	}
+
+	for _, credentials := range db.replicaCreds {
+		if db.debug {
+			log.WithField("credentials", credentials.String()).Debug("Open read replica connection")
+		}
+
+		sess, err := sql.Open(db.dialect.DriverName(), db.dialect.DSN(credentials))
+		if err != nil {
+			return fmt.Errorf("cannot connect to %s replica %s: %v", db.dialect.DriverName(), credentials.Address, err)
+		}
+		sess.SetMaxOpenConns(db.maxOpenConns)
+		sess.SetMaxIdleConns(db.maxIdleConns)
+
+		r := &replica{
+			address: credentials.Address,
+			sess:    sess,
+		}
+		r.check(context.Background())
+		db.replicas = append(db.replicas, r)
+	}
+
+	db.stop = make(chan struct{})
+	go db.checkReplicas()
+
+	return nil
+}
+
+func (db *Database) checkReplicas() {
+	ticker := time.NewTicker(db.healthCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			for _, r := range db.replicas {
+				r.check(context.Background())
+			}
+		}
+	}
+}
+
+func (db *Database) closeReplicas() error {
+	if db.stop != nil {
+		close(db.stop)
+		db.stop = nil
+	}
+	for _, r := range db.replicas {
+		if err := r.sess.Close(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// reader returns the executor that read operations should use: the transaction
+// if there is one, or a healthy replica in round robin if possible.
+func (db *Database) reader(ctx context.Context) executor {
+	if _, ok := ctx.Value(keyTx).(*sql.Tx); ok {
+		return db.executor(ctx)
+	}
+	if force, _ := ctx.Value(keyForcePrimary).(bool); force || len(db.replicas) == 0 {
+		return db.wrap(db.sess)
+	}
+
+	start := atomic.AddUint32(&db.next, 1)
+	for i := range db.replicas {
+		r := db.replicas[(int(start)+i)%len(db.replicas)]
+		if r.isHealthy() {
+			return db.wrap(r.sess)
+		}
+	}
+	return db.wrap(db.sess)
+}
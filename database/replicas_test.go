@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func initReplicasTestbed(t *testing.T, healthy ...bool) *Database {
+	// sql.Open does not connect to the database until the first query.
+	primary, err := sql.Open("mysql", "primary:password@tcp(localhost:3306)/default")
+	require.NoError(t, err)
+
+	db := &Database{sess: primary}
+	for _, h := range healthy {
+		sess, err := sql.Open("mysql", "replica:password@tcp(localhost:3306)/default")
+		require.NoError(t, err)
+
+		r := &replica{sess: sess}
+		if h {
+			r.healthy = 1
+		}
+		db.replicas = append(db.replicas, r)
+	}
+
+	return db
+}
+
+func TestReaderWithoutReplicas(t *testing.T) {
+	db := initReplicasTestbed(t)
+	require.Equal(t, db.reader(context.Background()), db.sess)
+}
+
+func TestReaderHealthyReplicas(t *testing.T) {
+	db := initReplicasTestbed(t, true, false, true)
+
+	require.Equal(t, db.reader(context.Background()), db.replicas[2].sess)
+	require.Equal(t, db.reader(context.Background()), db.replicas[2].sess)
+	require.Equal(t, db.reader(context.Background()), db.replicas[0].sess)
+}
+
+func TestReaderUnhealthyReplicas(t *testing.T) {
+	db := initReplicasTestbed(t, false, false)
+	require.Equal(t, db.reader(context.Background()), db.sess)
+}
+
+func TestReaderForcePrimary(t *testing.T) {
+	db := initReplicasTestbed(t, true)
+	require.Equal(t, db.reader(ForcePrimary(context.Background())), db.sess)
+}
+
+func TestCloseReplicasTwice(t *testing.T) {
+	db := initReplicasTestbed(t, true)
+	db.stop = make(chan struct{})
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Close())
+}
+
+func TestReplicasReadsOutsideTransaction(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	db, err := Open(Credentials{
+		User:      "dev-user",
+		Password:  "dev-password",
+		Address:   "localhost:3306",
+		Database:  "default",
+		Charset:   "utf8mb4",
+		Collation: "utf8mb4_bin",
+	}, WithReplicas(Credentials{
+		User:      "dev-user",
+		Password:  "dev-password",
+		Address:   "localhost:3306",
+		Database:  "default",
+		Charset:   "utf8mb4",
+		Collation: "utf8mb4_bin",
+	}), WithMaxOpenConns(5), WithMaxIdleConns(1))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.True(t, db.replicas[0].isHealthy())
+
+	c := db.Collection(new(testingModel))
+	require.NoError(t, c.Put(ctx, &testingModel{Code: "foo"}))
+
+	n, err := c.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 1)
+
+	err = db.RunTransaction(ctx, func(ctx context.Context) error {
+		require.IsType(t, db.reader(ctx), new(sql.Tx))
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// ColumnTyper can be implemented by custom column types to inform the SQL type
+// that should store them when generating the table schema.
+type ColumnTyper interface {
+	// ColumnType returns the SQL type of the column, e.g. "POINT" or "VARCHAR(50)".
+	ColumnType() string
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	columnTyperType = reflect.TypeOf((*ColumnTyper)(nil)).Elem()
+)
+
+type columnFamily string
+
+const (
+	familyInteger columnFamily = "integer"
+	familyFloat   columnFamily = "float"
+	familyBool    columnFamily = "bool"
+	familyString  columnFamily = "string"
+	familyBytes   columnFamily = "bytes"
+	familyTime    columnFamily = "time"
+	familyCustom  columnFamily = "custom"
+)
+
+var familyDataTypes = map[columnFamily][]string{
+	familyInteger: {"tinyint", "smallint", "mediumint", "int", "bigint"},
+	familyFloat:   {"float", "double", "decimal"},
+	familyBool:    {"tinyint", "bit"},
+	familyString:  {"char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json"},
+	familyBytes:   {"binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "json"},
+	familyTime:    {"date", "datetime", "timestamp"},
+}
+
+type columnDef struct {
+	prop     *Property
+	sqlType  string
+	family   columnFamily
+	nullable bool
+}
+
+func (col *columnDef) accepts(dataType string) bool {
+	if col.family == familyCustom {
+		base := strings.ToLower(strings.SplitN(col.sqlType, "(", 2)[0])
+		return base == dataType
+	}
+	for _, accepted := range familyDataTypes[col.family] {
+		if accepted == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+func modelColumns(model Model) ([]*columnDef, error) {
+	props, err := extractModelProps(model)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var cols []*columnDef
+	for _, prop := range props {
+		col := &columnDef{prop: prop}
+		t := reflect.TypeOf(prop.Pointer).Elem()
+		switch {
+		case reflect.PtrTo(t).Implements(columnTyperType):
+			col.family = familyCustom
+			col.sqlType = reflect.New(t).Interface().(ColumnTyper).ColumnType()
+
+		case t == timeType:
+			col.family, col.sqlType = familyTime, "DATETIME"
+		case t == reflect.TypeOf(NullableString("")), t == reflect.TypeOf(sql.NullString{}):
+			col.family, col.sqlType, col.nullable = familyString, "VARCHAR(191)", true
+		case t == reflect.TypeOf(sql.NullInt64{}):
+			col.family, col.sqlType, col.nullable = familyInteger, "BIGINT", true
+		case t == reflect.TypeOf(sql.NullInt32{}):
+			col.family, col.sqlType, col.nullable = familyInteger, "INT", true
+		case t == reflect.TypeOf(sql.NullFloat64{}):
+			col.family, col.sqlType, col.nullable = familyFloat, "DOUBLE", true
+		case t == reflect.TypeOf(sql.NullBool{}):
+			col.family, col.sqlType, col.nullable = familyBool, "BOOLEAN", true
+		case t == reflect.TypeOf(sql.NullTime{}):
+			col.family, col.sqlType, col.nullable = familyTime, "DATETIME", true
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+			col.family, col.sqlType = familyBytes, "BLOB"
+
+		case t.Kind() == reflect.String:
+			col.family, col.sqlType = familyString, "VARCHAR(191)"
+		case t.Kind() == reflect.Int64, t.Kind() == reflect.Int:
+			col.family, col.sqlType = familyInteger, "BIGINT"
+		case t.Kind() == reflect.Int32:
+			col.family, col.sqlType = familyInteger, "INT"
+		case t.Kind() == reflect.Float64:
+			col.family, col.sqlType = familyFloat, "DOUBLE"
+		case t.Kind() == reflect.Float32:
+			col.family, col.sqlType = familyFloat, "FLOAT"
+		case t.Kind() == reflect.Bool:
+			col.family, col.sqlType = familyBool, "BOOLEAN"
+
+		default:
+			return nil, errors.Errorf("cannot detect the SQL type of column %s with type %s, implement ColumnTyper in the type", prop.UnescapedName, t)
+		}
+
+		cols = append(cols, col)
+	}
+
+	return cols, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement of the table that stores the model.
+// Columns with custom types should implement ColumnTyper to be recognized.
+func CreateTableSQL(model Model) (string, error) {
+	cols, err := modelColumns(model)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	var pks []string
+	for _, col := range cols {
+		if col.prop.PrimaryKey {
+			pks = append(pks, col.prop.Name)
+		}
+	}
+
+	var lines []string
+	for _, col := range cols {
+		line := fmt.Sprintf("  %s %s", col.prop.Name, col.sqlType)
+		if col.nullable {
+			line += " NULL"
+		} else {
+			line += " NOT NULL"
+		}
+
+		switch {
+		case col.prop.PrimaryKey && len(pks) == 1 && col.family == familyInteger:
+			line += " AUTO_INCREMENT"
+
+		// Empty values are not sent in the INSERT and need a default to work.
+		case col.prop.OmitEmpty && !col.prop.PrimaryKey:
+			switch col.family {
+			case familyString:
+				line += " DEFAULT ''"
+			case familyInteger, familyFloat:
+				line += " DEFAULT 0"
+			case familyBool:
+				line += " DEFAULT FALSE"
+			}
+		}
+
+		lines = append(lines, line)
+	}
+	if len(pks) > 0 {
+		lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(pks, ", ")))
+	}
+
+	return fmt.Sprintf("CREATE TABLE `%s` (\n%s\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin", model.TableName(), strings.Join(lines, ",\n")), nil
+}
+
+// SchemaDiff lists the differences between a model and the table that stores it.
+type SchemaDiff struct {
+	Table string
+
+	// MissingTable is true if the table does not exist at all.
+	MissingTable bool
+
+	// MissingColumns are present in the model but not in the table.
+	MissingColumns []*ColumnDiff
+
+	// TypeMismatches are present in both, but the column type cannot be scanned
+	// into the model field.
+	TypeMismatches []*ColumnDiff
+
+	// ExtraColumns are present in the table but not in the model.
+	ExtraColumns []*ColumnDiff
+}
+
+// ColumnDiff describes a single column difference.
+type ColumnDiff struct {
+	Column string
+
+	// ModelType is the expected SQL type of the model field. Empty for extra columns.
+	ModelType string
+
+	// TableType is the SQL type of the column in the table. Empty for missing columns.
+	TableType string
+}
+
+// HasChanges returns true if the model and the table have any difference.
+func (diff *SchemaDiff) HasChanges() bool {
+	return diff.MissingTable || len(diff.MissingColumns) > 0 || len(diff.TypeMismatches) > 0 || len(diff.ExtraColumns) > 0
+}
+
+// String returns a human readable report of the differences.
+func (diff *SchemaDiff) String() string {
+	if diff.MissingTable {
+		return fmt.Sprintf("table %s: missing table", diff.Table)
+	}
+
+	var lines []string
+	for _, col := range diff.MissingColumns {
+		lines = append(lines, fmt.Sprintf("table %s: missing column %s %s", diff.Table, col.Column, col.ModelType))
+	}
+	for _, col := range diff.TypeMismatches {
+		lines = append(lines, fmt.Sprintf("table %s: column %s is %s, expected %s", diff.Table, col.Column, col.TableType, col.ModelType))
+	}
+	for _, col := range diff.ExtraColumns {
+		lines = append(lines, fmt.Sprintf("table %s: extra column %s %s", diff.Table, col.Column, col.TableType))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SchemaDiff compares the model against the INFORMATION_SCHEMA of the table that
+// stores it in the live database.
+func (db *Database) SchemaDiff(ctx context.Context, model Model) (*SchemaDiff, error) {
+	if db.dialect != MySQL {
+		return nil, errors.Errorf("schema diffs are only supported in MySQL databases")
+	}
+
+	cols, err := modelColumns(model)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	q := `
+		SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION
+	`
+	rows, err := db.executor(ctx).QueryContext(ctx, q, model.TableName())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	type tableColumn struct {
+		name, dataType, columnType string
+		nullable                   bool
+	}
+	var tableCols []*tableColumn
+	for rows.Next() {
+		col := new(tableColumn)
+		var nullable string
+		if err := rows.Scan(&col.name, &col.dataType, &col.columnType, &nullable); err != nil {
+			return nil, errors.Trace(err)
+		}
+		col.dataType = strings.ToLower(col.dataType)
+		col.nullable = nullable == "YES"
+		tableCols = append(tableCols, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	diff := &SchemaDiff{
+		Table: model.TableName(),
+	}
+	if len(tableCols) == 0 {
+		diff.MissingTable = true
+		return diff, nil
+	}
+
+	existing := make(map[string]*tableColumn)
+	for _, col := range tableCols {
+		existing[strings.ToLower(col.name)] = col
+	}
+	expected := make(map[string]bool)
+	for _, col := range cols {
+		name := strings.ToLower(col.prop.UnescapedName)
+		expected[name] = true
+
+		modelType := col.sqlType
+		if col.nullable {
+			modelType += " NULL"
+		} else {
+			modelType += " NOT NULL"
+		}
+
+		tableCol, ok := existing[name]
+		if !ok {
+			diff.MissingColumns = append(diff.MissingColumns, &ColumnDiff{
+				Column:    col.prop.UnescapedName,
+				ModelType: modelType,
+			})
+			continue
+		}
+
+		// A nullable column will fail when scanning NULL in a field that does not support it.
+		if !col.accepts(tableCol.dataType) || (tableCol.nullable && !col.nullable) {
+			tableType := tableCol.columnType
+			if tableCol.nullable {
+				tableType += " NULL"
+			} else {
+				tableType += " NOT NULL"
+			}
+			diff.TypeMismatches = append(diff.TypeMismatches, &ColumnDiff{
+				Column:    col.prop.UnescapedName,
+				ModelType: modelType,
+				TableType: tableType,
+			})
+		}
+	}
+	for _, col := range tableCols {
+		if !expected[strings.ToLower(col.name)] {
+			diff.ExtraColumns = append(diff.ExtraColumns, &ColumnDiff{
+				Column:    col.name,
+				TableType: col.columnType,
+			})
+		}
+	}
+
+	return diff, nil
+}
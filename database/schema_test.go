@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testingSchemaModel struct {
+	ModelTracking
+
+	Code      string         `db:"code,pk"`
+	Name      string         `db:"name,omitempty"`
+	Count     int32          `db:"count"`
+	Enabled   bool           `db:"enabled"`
+	Created   time.Time      `db:"created"`
+	Optional  sql.NullString `db:"optional"`
+	Ignored   string         `db:"-"`
+	Unchanged NullableString `db:"unchanged"`
+}
+
+func (model *testingSchemaModel) TableName() string {
+	return "testing_schema"
+}
+
+type testingSchemaInvalid struct {
+	ModelTracking
+
+	ID    int64             `db:"id,pk"`
+	Attrs map[string]string `db:"attrs"`
+}
+
+func (model *testingSchemaInvalid) TableName() string {
+	return "testing_schema_invalid"
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	stmt, err := CreateTableSQL(new(testingSchemaModel))
+	require.NoError(t, err)
+	require.Equal(t, stmt, "CREATE TABLE `testing_schema` (\n"+
+		"  `revision` BIGINT NOT NULL,\n"+
+		"  `code` VARCHAR(191) NOT NULL,\n"+
+		"  `name` VARCHAR(191) NOT NULL DEFAULT '',\n"+
+		"  `count` INT NOT NULL,\n"+
+		"  `enabled` BOOLEAN NOT NULL,\n"+
+		"  `created` DATETIME NOT NULL,\n"+
+		"  `optional` VARCHAR(191) NULL,\n"+
+		"  `unchanged` VARCHAR(191) NULL,\n"+
+		"  PRIMARY KEY (`code`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin")
+}
+
+func TestCreateTableSQLAutoIncrement(t *testing.T) {
+	stmt, err := CreateTableSQL(new(testingAutoModel))
+	require.NoError(t, err)
+	require.Contains(t, stmt, "`id` BIGINT NOT NULL AUTO_INCREMENT,\n")
+}
+
+func TestCreateTableSQLUnknownType(t *testing.T) {
+	_, err := CreateTableSQL(new(testingSchemaInvalid))
+	require.EqualError(t, err, "cannot detect the SQL type of column attrs with type map[string]string, implement ColumnTyper in the type")
+}
+
+func TestSchemaDiffCreatedTable(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS testing_schema`))
+	stmt, err := CreateTableSQL(new(testingSchemaModel))
+	require.NoError(t, err)
+	require.NoError(t, testDB.Exec(ctx, stmt))
+
+	diff, err := testDB.SchemaDiff(ctx, new(testingSchemaModel))
+	require.NoError(t, err)
+	require.False(t, diff.HasChanges())
+}
+
+func TestSchemaDiffMissingTable(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS testing_schema`))
+
+	diff, err := testDB.SchemaDiff(ctx, new(testingSchemaModel))
+	require.NoError(t, err)
+	require.True(t, diff.MissingTable)
+}
+
+func TestSchemaDiffChanges(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS testing_schema`))
+	err := testDB.Exec(ctx, `
+    CREATE TABLE testing_schema (
+      code VARCHAR(191) NOT NULL,
+      name VARCHAR(191) NOT NULL,
+      count DATETIME NOT NULL,
+      enabled BOOLEAN NOT NULL,
+      created DATETIME NOT NULL,
+      optional VARCHAR(191),
+      unchanged VARCHAR(191),
+      extra VARCHAR(191),
+
+      PRIMARY KEY(code)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+  `)
+	require.NoError(t, err)
+
+	diff, err := testDB.SchemaDiff(ctx, new(testingSchemaModel))
+	require.NoError(t, err)
+	require.True(t, diff.HasChanges())
+
+	require.Len(t, diff.MissingColumns, 1)
+	require.Equal(t, diff.MissingColumns[0].Column, "revision")
+
+	require.Len(t, diff.TypeMismatches, 1)
+	require.Equal(t, diff.TypeMismatches[0].Column, "count")
+	require.Equal(t, diff.TypeMismatches[0].ModelType, "INT NOT NULL")
+	require.Equal(t, diff.TypeMismatches[0].TableType, "datetime NOT NULL")
+
+	require.Len(t, diff.ExtraColumns, 1)
+	require.Equal(t, diff.ExtraColumns[0].Column, "extra")
+}
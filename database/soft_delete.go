@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/altipla-consulting/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var nullTimeType = reflect.TypeOf(sql.NullTime{})
+
+type deletedMode int
+
+const (
+	deletedHide deletedMode = iota
+	deletedInclude
+	deletedOnly
+)
+
+// WithSoftDelete enables soft deletes in the collection. The column should be a
+// sql.NullTime field of the model. Delete will stamp the column with the current
+// time instead of removing the row, and read operations will hide those rows
+// unless WithDeleted or OnlyDeleted are called.
+func WithSoftDelete(column string) CollectionOption {
+	return func(c *Collection) {
+		for _, prop := range c.props {
+			if prop.UnescapedName == column {
+				if reflect.TypeOf(prop.Pointer).Elem() != nullTimeType {
+					panic(fmt.Sprintf("soft delete column %s should be a sql.NullTime", column))
+				}
+				c.softDelete = prop
+				return
+			}
+		}
+
+		panic(fmt.Sprintf("soft delete column %s not found in the model", column))
+	}
+}
+
+// WithDeleted includes the soft deleted rows in the read operations of the collection.
+func (c *Collection) WithDeleted() *Collection {
+	if c.softDelete == nil {
+		panic("cannot use WithDeleted in a collection without soft deletes")
+	}
+
+	c.deleted = deletedInclude
+	return c
+}
+
+// OnlyDeleted returns only the soft deleted rows in the read operations of the collection.
+func (c *Collection) OnlyDeleted() *Collection {
+	if c.softDelete == nil {
+		panic("cannot use OnlyDeleted in a collection without soft deletes")
+	}
+
+	c.deleted = deletedOnly
+	return c
+}
+
+// Restore clears the deleted column of a soft deleted model. It uses the filters
+// and the model primary key to find the row to restore.
+func (c *Collection) Restore(ctx context.Context, instance Model) error {
+	if c.softDelete == nil {
+		return errors.Errorf("cannot restore models in a collection without soft deletes")
+	}
+
+	return errors.Trace(c.updateDeleted(ctx, []Model{instance}, sql.NullTime{}))
+}
+
+// queryConditions returns the conditions that read operations should apply,
+// including the soft delete ones.
+func (c *Collection) queryConditions() []Condition {
+	if c.softDelete == nil || c.deleted == deletedInclude {
+		return c.conditions
+	}
+
+	column := c.softDelete.Name
+	if c.alias != "" {
+		column = c.alias + "." + column
+	}
+
+	conditions := make([]Condition, len(c.conditions), len(c.conditions)+1)
+	copy(conditions, c.conditions)
+	if c.deleted == deletedOnly {
+		return append(conditions, FilterIsNotNil(column))
+	}
+	return append(conditions, FilterIsNil(column))
+}
+
+func (c *Collection) updateDeleted(ctx context.Context, instances []Model, deleted sql.NullTime) error {
+	conditions := make([]Condition, len(c.conditions), len(c.conditions)+1)
+	copy(conditions, c.conditions)
+	b := &sqlBuilder{
+		table:      c.model.TableName(),
+		conditions: append(conditions, c.pkCondition(instances)),
+		props: []*Property{
+			{
+				Name:          c.softDelete.Name,
+				UnescapedName: c.softDelete.UnescapedName,
+				Value:         deleted,
+			},
+		},
+	}
+
+	statement, values := b.UpdateSQL()
+	if c.db.debug {
+		log.Println("database [SoftDelete]:", statement)
+	}
+
+	if _, err := c.db.executor(ctx).ExecContext(ctx, statement, values...); err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, instance := range instances {
+		reflect.ValueOf(instance).Elem().FieldByName(c.softDelete.Field).Set(reflect.ValueOf(deleted))
+	}
+
+	return nil
+}
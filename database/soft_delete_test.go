@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testingSoftModel struct {
+	ModelTracking
+
+	Code      string       `db:"code,pk"`
+	Name      string       `db:"name"`
+	DeletedAt sql.NullTime `db:"deleted_at"`
+}
+
+func (model *testingSoftModel) TableName() string {
+	return "testing_soft"
+}
+
+func initSoftDelete(t *testing.T) *Collection {
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Exec(ctx, `DROP TABLE IF EXISTS testing_soft`))
+	err := testDB.Exec(ctx, `
+    CREATE TABLE testing_soft (
+      code VARCHAR(191),
+      name VARCHAR(191),
+      deleted_at DATETIME,
+      revision INT(11) NOT NULL,
+
+      PRIMARY KEY(code)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+  `)
+	require.NoError(t, err)
+
+	c := testDB.Collection(new(testingSoftModel), WithSoftDelete("deleted_at"))
+	require.NoError(t, c.PutMulti(ctx, []*testingSoftModel{
+		{Code: "foo", Name: "foo name"},
+		{Code: "bar", Name: "bar name"},
+	}))
+
+	return c
+}
+
+func TestSoftDeleteInvalidColumn(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+
+	require.PanicsWithValue(t, "soft delete column name should be a sql.NullTime", func() {
+		testDB.Collection(new(testingSoftModel), WithSoftDelete("name"))
+	})
+	require.PanicsWithValue(t, "soft delete column unknown not found in the model", func() {
+		testDB.Collection(new(testingSoftModel), WithSoftDelete("unknown"))
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	c := initSoftDelete(t)
+
+	m := &testingSoftModel{Code: "foo"}
+	require.NoError(t, c.Get(ctx, m))
+	require.NoError(t, c.Delete(ctx, m))
+	require.True(t, m.DeletedAt.Valid)
+
+	require.EqualError(t, c.Get(ctx, &testingSoftModel{Code: "foo"}), ErrNoSuchEntity.Error())
+
+	var models []*testingSoftModel
+	require.NoError(t, c.GetAll(ctx, &models))
+	require.Len(t, models, 1)
+	require.Equal(t, models[0].Code, "bar")
+
+	n, err := c.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 1)
+
+	var raw int64
+	require.NoError(t, testDB.QueryRow(ctx, `SELECT COUNT(*) FROM testing_soft`).Scan(&raw))
+	require.EqualValues(t, raw, 2)
+}
+
+func TestSoftDeleteWithDeleted(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	c := initSoftDelete(t)
+
+	require.NoError(t, c.Delete(ctx, &testingSoftModel{Code: "foo"}))
+
+	n, err := c.Clone().WithDeleted().Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 2)
+
+	other := new(testingSoftModel)
+	require.NoError(t, c.Clone().OnlyDeleted().First(ctx, other))
+	require.Equal(t, other.Code, "foo")
+	require.True(t, other.DeletedAt.Valid)
+}
+
+func TestSoftDeleteRestore(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	c := initSoftDelete(t)
+
+	m := &testingSoftModel{Code: "foo"}
+	require.NoError(t, c.Delete(ctx, m))
+	require.NoError(t, c.Restore(ctx, m))
+	require.False(t, m.DeletedAt.Valid)
+
+	other := &testingSoftModel{Code: "foo"}
+	require.NoError(t, c.Get(ctx, other))
+	require.Equal(t, other.Name, "foo name")
+}
+
+func TestSoftDeleteHardDelete(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	c := initSoftDelete(t)
+
+	require.NoError(t, c.HardDelete(ctx, &testingSoftModel{Code: "foo"}))
+
+	n, err := c.Clone().WithDeleted().Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 1)
+}
+
+func TestSoftDeleteMulti(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+	c := initSoftDelete(t)
+
+	models := []*testingSoftModel{
+		{Code: "foo"},
+		{Code: "bar"},
+	}
+	require.NoError(t, c.DeleteMulti(ctx, models))
+
+	n, err := c.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 0)
+
+	n, err = c.Clone().OnlyDeleted().Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 2)
+}
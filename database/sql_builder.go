@@ -12,6 +12,9 @@ type sqlBuilder struct {
 	conditions    []Condition
 	limit, offset int64
 	alias         string
+	joins         []*sqlJoin
+	groupBy       []string
+	having        []Condition
 }
 
 func (b *sqlBuilder) cols() []string {
@@ -23,31 +26,80 @@ func (b *sqlBuilder) cols() []string {
 	return cols
 }
 
+// qualifiedCols returns the columns prefixed with the table name or alias when
+// there are joins in the query, to avoid ambiguous names between both tables.
+func (b *sqlBuilder) qualifiedCols() []string {
+	if len(b.joins) == 0 {
+		return b.cols()
+	}
+
+	var cols []string
+	for _, prop := range b.props {
+		cols = append(cols, b.qualify(prop.Name))
+	}
+	return cols
+}
+
+// qualify prefixes the column with the table name or alias if there are joins
+// in the query.
+func (b *sqlBuilder) qualify(col string) string {
+	if len(b.joins) == 0 {
+		return col
+	}
+	return b.qualifier() + "." + col
+}
+
+func (b *sqlBuilder) qualifier() string {
+	if b.alias != "" {
+		return b.alias
+	}
+	return b.table
+}
+
 func (b *sqlBuilder) SelectSQL() (string, []interface{}) {
-	return b.SelectSQLCols(b.cols()...)
+	return b.SelectSQLCols(b.qualifiedCols()...)
 }
 
 func (b *sqlBuilder) SelectSQLCols(cols ...string) (string, []interface{}) {
-	var conds []string
 	var values []interface{}
-	for _, cond := range b.conditions {
-		conds = append(conds, cond.SQL())
-		values = append(values, cond.Values()...)
-	}
 
 	sql := fmt.Sprintf(`SELECT %s FROM %s`, strings.Join(cols, ", "), b.table)
 	if b.alias != "" {
 		sql = fmt.Sprintf("%s AS %s", sql, b.alias)
 	}
 
+	for _, join := range b.joins {
+		joinSQL, joinValues := join.SQL()
+		sql = fmt.Sprintf("%s %s", sql, joinSQL)
+		values = append(values, joinValues...)
+	}
+
+	var conds []string
+	for _, cond := range b.conditions {
+		conds = append(conds, cond.SQL())
+		values = append(values, cond.Values()...)
+	}
 	if len(conds) > 0 {
 		sql = fmt.Sprintf("%s WHERE %s", sql, strings.Join(conds, " AND "))
 	}
+
+	if len(b.groupBy) > 0 {
+		sql = fmt.Sprintf("%s GROUP BY %s", sql, strings.Join(b.groupBy, ", "))
+	}
+	var having []string
+	for _, cond := range b.having {
+		having = append(having, cond.SQL())
+		values = append(values, cond.Values()...)
+	}
+	if len(having) > 0 {
+		sql = fmt.Sprintf("%s HAVING %s", sql, strings.Join(having, " AND "))
+	}
+
 	if len(b.orders) > 0 {
 		sql = fmt.Sprintf("%s ORDER BY %s", sql, strings.Join(b.orders, ", "))
 	}
 	if b.limit > 0 {
-		sql = fmt.Sprintf("%s LIMIT %d,%d", sql, b.offset, b.limit)
+		sql = fmt.Sprintf("%s LIMIT %d OFFSET %d", sql, b.limit, b.offset)
 	}
 
 	return sql, values
@@ -99,3 +151,56 @@ func (b *sqlBuilder) DeleteSQL() (string, []interface{}) {
 
 	return sql, values
 }
+
+func (b *sqlBuilder) UpsertMultiSQL(rows [][]*Property, dialect Dialect) (string, []interface{}) {
+	var values []interface{}
+
+	placeholders := make([]string, len(b.props))
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+	group := "(" + strings.Join(placeholders, ", ") + ")"
+
+	groups := make([]string, len(rows))
+	for i, row := range rows {
+		groups[i] = group
+		for _, prop := range row {
+			values = append(values, prop.Value)
+		}
+	}
+
+	var pks, updates []string
+	for _, prop := range b.props {
+		if prop.PrimaryKey {
+			pks = append(pks, prop.Name)
+			continue
+		}
+		updates = append(updates, prop.Name)
+	}
+
+	sql := fmt.Sprintf(`INSERT INTO %s(%s) VALUES %s`, b.table, strings.Join(b.cols(), ", "), strings.Join(groups, ", "))
+	if upsert := dialect.UpsertSQL(pks, updates); upsert != "" {
+		sql = fmt.Sprintf("%s %s", sql, upsert)
+	}
+
+	return sql, values
+}
+
+type sqlJoin struct {
+	kind       string
+	table      string
+	alias      string
+	on         string
+	conditions []Condition
+}
+
+func (join *sqlJoin) SQL() (string, []interface{}) {
+	conds := []string{"(" + join.on + ")"}
+	var values []interface{}
+	for _, cond := range join.conditions {
+		conds = append(conds, cond.SQL())
+		values = append(values, cond.Values()...)
+	}
+
+	return fmt.Sprintf("%s %s AS %s ON %s", join.kind, join.table, join.alias, strings.Join(conds, " AND ")), values
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+// MySQL error numbers that abort the transaction and can be solved retrying it.
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// SQLSTATE codes of PostgreSQL that abort the transaction and can be solved retrying it.
+const (
+	stateSerializationFailure = "40001"
+	stateDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy configures the automatic retries of transactions that fail because
+// of a deadlock or a lock wait timeout.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the transaction will run,
+	// including the first one.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry. It doubles after each one.
+	Backoff time.Duration
+
+	// MaxBackoff limits the wait between retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is a reasonable retry policy that can be passed to WithTransactionRetry.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     50 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
+// WithTransactionRetry runs again the whole transaction when it fails because of
+// a deadlock or a lock wait timeout. The transactional function may be called
+// multiple times, avoid side effects outside the database inside it.
+func WithTransactionRetry(policy RetryPolicy) Option {
+	if policy.MaxAttempts < 1 {
+		panic("cannot configure a retry policy with less than 1 attempt")
+	}
+
+	return func(db *Database) {
+		db.retry = policy
+	}
+}
+
+type TransactionalFn func(ctx context.Context) error
+
+// RunTransaction runs fn inside a transaction. The context passed to fn contains
+// the transaction and should be used in every database operation inside it.
+// The transaction will be rolled back if fn returns an error and committed otherwise.
+//
+// Nested calls will create a SAVEPOINT inside the current transaction instead. If the
+// nested fn returns an error only the changes made since the savepoint will be rolled
+// back and the outer transaction can continue.
+func (db *Database) RunTransaction(ctx context.Context, fn TransactionalFn) error {
+	if _, ok := ctx.Value(keyTx).(*sql.Tx); ok {
+		return errors.Trace(db.runSavepoint(ctx, fn))
+	}
+
+	attempts := db.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := db.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := db.runTransaction(ctx, fn)
+		if err == nil || attempt >= attempts || !isRetryable(err) {
+			return errors.Trace(err)
+		}
+
+		if db.debug {
+			log.WithFields(log.Fields{
+				"attempt": attempt,
+				"error":   err.Error(),
+			}).Debug("Retry database transaction")
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if db.retry.MaxBackoff > 0 && backoff > db.retry.MaxBackoff {
+			backoff = db.retry.MaxBackoff
+		}
+	}
+}
+
+func (db *Database) runTransaction(ctx context.Context, fn TransactionalFn) error {
+	tx, err := db.sess.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = context.WithValue(ctx, keyTx, tx)
+
+	if err := fn(ctx); err != nil {
+		if err := tx.Rollback(); err != nil {
+			return fmt.Errorf("unable to rollback transaction: %w", err)
+		}
+
+		return errors.Trace(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (db *Database) runSavepoint(ctx context.Context, fn TransactionalFn) error {
+	tx := ctx.Value(keyTx).(*sql.Tx)
+	depth, _ := ctx.Value(keySavepoint).(int)
+	depth++
+	name := fmt.Sprintf("sp_%d", depth)
+	ctx = context.WithValue(ctx, keySavepoint, depth)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := fn(ctx); err != nil {
+		// Deadlocks roll back the whole transaction and remove the savepoints. Return
+		// the original error to let the outer transaction retry.
+		if abortsTransaction(err) {
+			return errors.Trace(err)
+		}
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			return fmt.Errorf("unable to rollback savepoint: %w", err)
+		}
+
+		return errors.Trace(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// inTransaction runs fn inside the transaction of the context, or opens a new
+// one if there is none.
+func (db *Database) inTransaction(ctx context.Context, fn TransactionalFn) error {
+	if _, ok := ctx.Value(keyTx).(*sql.Tx); ok {
+		return errors.Trace(fn(ctx))
+	}
+	return errors.Trace(db.RunTransaction(ctx, fn))
+}
+
+// abortsTransaction reports whether the error already rolled back the whole
+// transaction in the server. Other retryable errors, like lock wait timeouts,
+// only fail the last statement and keep the transaction alive.
+func abortsTransaction(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errDeadlock
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == stateSerializationFailure || stateErr.SQLState() == stateDeadlockDetected
+	}
+
+	return false
+}
+
+func isRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+
+	// Both github.com/lib/pq and github.com/jackc/pgx errors implement this method.
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == stateSerializationFailure || stateErr.SQLState() == stateDeadlockDetected
+	}
+
+	return false
+}
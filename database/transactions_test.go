@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryable(t *testing.T) {
+	require.True(t, isRetryable(errors.Trace(&mysql.MySQLError{Number: 1213})))
+	require.True(t, isRetryable(&mysql.MySQLError{Number: 1205}))
+	require.False(t, isRetryable(&mysql.MySQLError{Number: 1062}))
+	require.False(t, isRetryable(errors.New("foo")))
+}
+
+func TestNestedTransactionRollbackSavepoint(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, testings.Put(ctx, &testingModel{Code: "foo"}))
+
+		err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, testings.Put(ctx, &testingModel{Code: "bar"}))
+			return errors.New("nested failure")
+		})
+		require.EqualError(t, err, "nested failure")
+
+		return testDB.RunTransaction(ctx, func(ctx context.Context) error {
+			return testings.Put(ctx, &testingModel{Code: "baz"})
+		})
+	})
+	require.NoError(t, err)
+
+	var models []*testingModel
+	require.NoError(t, testings.Order("code").GetAll(ctx, &models))
+	require.Len(t, models, 2)
+	require.Equal(t, models[0].Code, "baz")
+	require.Equal(t, models[1].Code, "foo")
+}
+
+func TestAbortsTransaction(t *testing.T) {
+	require.True(t, abortsTransaction(errors.Trace(&mysql.MySQLError{Number: 1213})))
+	require.False(t, abortsTransaction(&mysql.MySQLError{Number: 1205}))
+	require.False(t, abortsTransaction(errors.New("foo")))
+}
+
+func TestNestedTransactionLockWaitTimeout(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, testings.Put(ctx, &testingModel{Code: "foo"}))
+
+		err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, testings.Put(ctx, &testingModel{Code: "bar"}))
+			return errors.Trace(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+		})
+		require.True(t, isRetryable(err))
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	var models []*testingModel
+	require.NoError(t, testings.GetAll(ctx, &models))
+	require.Len(t, models, 1)
+	require.Equal(t, models[0].Code, "foo")
+}
+
+func TestTransactionRetry(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	testDB.retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	var calls int
+	err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.Trace(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+		}
+		return testings.Put(ctx, &testingModel{Code: "foo"})
+	})
+	require.NoError(t, err)
+	require.Equal(t, calls, 3)
+}
+
+func TestTransactionRetryExhausted(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase(t)
+	ctx := context.Background()
+
+	testDB.retry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+
+	var calls int
+	err := testDB.RunTransaction(ctx, func(ctx context.Context) error {
+		calls++
+		return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	})
+	require.True(t, isRetryable(err))
+	require.Equal(t, calls, 2)
+}
@@ -153,16 +153,20 @@ func (ctrl *sharedController) rdbStorage() *rdbStorage {
 }
 
 func (ctrl *sharedController) setPageSize(pageSize int32) {
+	ctrl.pageSize = normalizePageSize(pageSize, ctrl.maxPageSize)
+}
+
+func normalizePageSize(pageSize, maxPageSize int32) int32 {
 	if pageSize < 0 {
 		pageSize = 0
 	}
 	if pageSize == 0 {
 		pageSize = 100
 	}
-	if pageSize > ctrl.maxPageSize {
-		pageSize = ctrl.maxPageSize
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
 	}
-	ctrl.pageSize = pageSize
+	return pageSize
 }
 
 // OutOfBounds returns true if the requested page is out of bounds.
@@ -0,0 +1,354 @@
+package pagination
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/database"
+)
+
+// defaultSigningKey is generated randomly for each process. Tokens signed with it
+// are not valid after a restart nor in other instances of the application.
+var defaultSigningKey = randomSigningKey()
+
+func randomSigningKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("cannot generate the keyset signing key: %v", err))
+	}
+	return key
+}
+
+// WithSigningKey changes the key used to sign the keyset tokens. Without it a
+// random key is generated when the process starts, so tokens won't survive
+// restarts nor work across multiple instances of the application. It has no
+// effect in the other paginators.
+func WithSigningKey(key []byte) ControllerOption {
+	if len(key) == 0 {
+		panic("cannot configure an empty signing key")
+	}
+
+	return func(ctrl Controller) {
+		if keyset, ok := ctrl.(*KeysetController); ok {
+			keyset.signingKey = key
+		}
+	}
+}
+
+// NewSQLKeyset creates a paginator for a MySQL query using keyset tokens. Instead
+// of skipping rows with an offset, each token stores the values of the order columns
+// of the last row read and the next page continues from there. The primary keys
+// are added at the end of the order to have a stable order.
+//
+// It is faster in big tables and won't skip nor duplicate rows when the data changes
+// between pages. In exchange it cannot report the total size of the query and the
+// collection cannot be ordered with OrderSorter or by nullable columns.
+func NewSQLKeyset(q *database.Collection, input InputAdapter, opts ...ControllerOption) *KeysetController {
+	ctrl := &KeysetController{
+		q:           q,
+		maxPageSize: DefaultMaxPageSize,
+		signingKey:  defaultSigningKey,
+	}
+	for _, opt := range opts {
+		opt(ctrl)
+	}
+	input(ctrl)
+	return ctrl
+}
+
+type KeysetController struct {
+	q                *database.Collection
+	maxPageSize      int32
+	pageSize         int32
+	checksum         uint32
+	signingKey       []byte
+	token            string
+	next, prev       database.Cursor
+	hasNext, hasPrev bool
+}
+
+func (ctrl *KeysetController) setMaxPageSize(maxPageSize int32) {
+	ctrl.maxPageSize = maxPageSize
+}
+
+func (ctrl *KeysetController) rdbStorage() *rdbStorage {
+	return nil
+}
+
+func (ctrl *KeysetController) setPageSize(pageSize int32) {
+	ctrl.pageSize = normalizePageSize(pageSize, ctrl.maxPageSize)
+}
+
+func (ctrl *KeysetController) setToken(token string) {
+	ctrl.token = token
+}
+
+func (ctrl *KeysetController) setPage(page int32, checksum uint32) {
+}
+
+// Fetch obtains the requested page of items.
+func (ctrl *KeysetController) Fetch(ctx context.Context, models interface{}) error {
+	// Checksum the query including the page size.
+	ctrl.checksum = ctrl.q.Clone().Limit(int64(ctrl.pageSize)).Checksum()
+
+	var token *keysetToken
+	if ctrl.token != "" {
+		var err error
+		token, err = ctrl.decodeToken(ctrl.token)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	base := ctrl.q.Clone().StartAfter(nil)
+	q := base.Clone()
+	if token != nil && token.Prev {
+		q.Reverse()
+	}
+	if token != nil {
+		cursor, err := token.cursor()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		q.StartAfter(cursor)
+	}
+
+	// Read an additional row to know if there are more pages after this one.
+	dest := reflect.New(reflect.TypeOf(models).Elem())
+	if err := q.Limit(int64(ctrl.pageSize)+1).GetAll(ctx, dest.Interface()); err != nil {
+		return errors.Trace(err)
+	}
+	results := dest.Elem()
+	more := results.Len() > int(ctrl.pageSize)
+	if more {
+		results = results.Slice(0, int(ctrl.pageSize))
+	}
+	if token != nil && token.Prev {
+		for i, j := 0, results.Len()-1; i < j; i, j = i+1, j-1 {
+			a, b := results.Index(i).Interface(), results.Index(j).Interface()
+			results.Index(i).Set(reflect.ValueOf(b))
+			results.Index(j).Set(reflect.ValueOf(a))
+		}
+	}
+	reflect.ValueOf(models).Elem().Set(results)
+
+	switch {
+	case token == nil:
+		ctrl.hasNext, ctrl.hasPrev = more, false
+	case token.Prev:
+		ctrl.hasNext, ctrl.hasPrev = true, more
+	default:
+		ctrl.hasNext, ctrl.hasPrev = more, true
+	}
+
+	ctrl.next, ctrl.prev = nil, nil
+	if results.Len() > 0 {
+		var err error
+		ctrl.prev, err = base.Cursor(results.Index(0).Interface().(database.Model))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		ctrl.next, err = base.Cursor(results.Index(results.Len() - 1).Interface().(database.Model))
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// HasNextPage returns true if there is a next page.
+func (ctrl *KeysetController) HasNextPage() bool {
+	return ctrl.hasNext && ctrl.next != nil
+}
+
+// HasPrevPage returns true if there is a previous page.
+func (ctrl *KeysetController) HasPrevPage() bool {
+	return ctrl.hasPrev && ctrl.prev != nil
+}
+
+// PageSize returns the page size.
+func (ctrl *KeysetController) PageSize() int32 {
+	return ctrl.pageSize
+}
+
+// Checksum returns the internal checksum that must validate to perform the query.
+func (ctrl *KeysetController) Checksum() uint32 {
+	return ctrl.checksum
+}
+
+// NextPageToken returns a token that can be used to fetch the next page.
+func (ctrl *KeysetController) NextPageToken() string {
+	if !ctrl.HasNextPage() {
+		return ""
+	}
+	return ctrl.encodeToken(ctrl.next, false)
+}
+
+// PrevPageToken returns a token that can be used to fetch the previous page.
+func (ctrl *KeysetController) PrevPageToken() string {
+	if !ctrl.HasPrevPage() {
+		return ""
+	}
+	return ctrl.encodeToken(ctrl.prev, true)
+}
+
+// NextPageURL modifies the URL to point to the next page.
+func (ctrl *KeysetController) NextPageURL(u *url.URL) *url.URL {
+	if !ctrl.HasNextPage() {
+		return nil
+	}
+
+	qs := u.Query()
+	qs.Set("token", ctrl.NextPageToken())
+	u.RawQuery = qs.Encode()
+
+	return u
+}
+
+// PrevPageURL modifies the URL to point to the previous page.
+func (ctrl *KeysetController) PrevPageURL(u *url.URL) *url.URL {
+	if !ctrl.HasPrevPage() {
+		return nil
+	}
+
+	qs := u.Query()
+	qs.Set("token", ctrl.PrevPageToken())
+	u.RawQuery = qs.Encode()
+
+	return u
+}
+
+// NextPageURLString returns a new URL based on the current one for the next page.
+func (ctrl *KeysetController) NextPageURLString(r *http.Request) string {
+	u := new(url.URL)
+	*u = *r.URL
+	if next := ctrl.NextPageURL(u); next != nil {
+		return next.String()
+	}
+	return ""
+}
+
+// PrevPageURLString returns a new URL based on the current one for the previous page.
+func (ctrl *KeysetController) PrevPageURLString(r *http.Request) string {
+	u := new(url.URL)
+	*u = *r.URL
+	if prev := ctrl.PrevPageURL(u); prev != nil {
+		return prev.String()
+	}
+	return ""
+}
+
+type keysetToken struct {
+	Checksum uint32        `json:"c"`
+	Prev     bool          `json:"p,omitempty"`
+	Values   []keysetValue `json:"v"`
+}
+
+func (token *keysetToken) cursor() (database.Cursor, error) {
+	cursor := make(database.Cursor, len(token.Values))
+	for i, v := range token.Values {
+		switch {
+		case v.Int != nil:
+			cursor[i] = *v.Int
+		case v.Float != nil:
+			cursor[i] = *v.Float
+		case v.Bool != nil:
+			cursor[i] = *v.Bool
+		case v.String != nil:
+			cursor[i] = *v.String
+		case v.Bytes != nil:
+			cursor[i] = *v.Bytes
+		case v.Time != nil:
+			cursor[i] = *v.Time
+		default:
+			return nil, fmt.Errorf("empty value %d inside the token: %w", i, ErrInvalidToken)
+		}
+	}
+	return cursor, nil
+}
+
+// keysetValue stores the cursor values keeping their original type when
+// serialized to JSON.
+type keysetValue struct {
+	Int    *int64     `json:"i,omitempty"`
+	Float  *float64   `json:"f,omitempty"`
+	Bool   *bool      `json:"b,omitempty"`
+	String *string    `json:"s,omitempty"`
+	Bytes  *[]byte    `json:"y,omitempty"`
+	Time   *time.Time `json:"t,omitempty"`
+}
+
+func (ctrl *KeysetController) encodeToken(cursor database.Cursor, prev bool) string {
+	token := &keysetToken{
+		Checksum: ctrl.checksum,
+		Prev:     prev,
+	}
+	for _, value := range cursor {
+		var v keysetValue
+		switch value := value.(type) {
+		case int64:
+			v.Int = &value
+		case float64:
+			v.Float = &value
+		case bool:
+			v.Bool = &value
+		case string:
+			v.String = &value
+		case []byte:
+			v.Bytes = &value
+		case time.Time:
+			v.Time = &value
+		default:
+			panic(fmt.Sprintf("unsupported cursor value of type %T", value))
+		}
+		token.Values = append(token.Values, v)
+	}
+
+	payload, err := json.Marshal(token)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, ctrl.sign(payload)...))
+}
+
+func (ctrl *KeysetController) decodeToken(s string) (*keysetToken, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode token %q: %v: %w", s, err, ErrInvalidToken)
+	}
+	if len(decoded) <= sha256.Size {
+		return nil, fmt.Errorf("token %q is too short: %w", s, ErrInvalidToken)
+	}
+
+	payload, signature := decoded[:len(decoded)-sha256.Size], decoded[len(decoded)-sha256.Size:]
+	if !hmac.Equal(signature, ctrl.sign(payload)) {
+		return nil, fmt.Errorf("invalid signature in token %q: %w", s, ErrInvalidToken)
+	}
+
+	token := new(keysetToken)
+	if err := json.Unmarshal(payload, token); err != nil {
+		return nil, fmt.Errorf("cannot decode token %q: %v: %w", s, err, ErrInvalidToken)
+	}
+	if token.Checksum != ctrl.checksum {
+		return nil, fmt.Errorf("checksum mismatch for token %q: got %v, expected %v: %w", s, token.Checksum, ctrl.checksum, ErrChecksumMismatch)
+	}
+	return token, nil
+}
+
+func (ctrl *KeysetController) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, ctrl.signingKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
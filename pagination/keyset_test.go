@@ -0,0 +1,120 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/database"
+)
+
+func TestKeysetTokenRoundtrip(t *testing.T) {
+	ctrl := &KeysetController{signingKey: defaultSigningKey, checksum: 42}
+
+	now := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	token := ctrl.encodeToken(database.Cursor{int64(3), "foo", now, true, 1.5, []byte("bar")}, true)
+
+	decoded, err := ctrl.decodeToken(token)
+	require.NoError(t, err)
+	require.True(t, decoded.Prev)
+
+	cursor, err := decoded.cursor()
+	require.NoError(t, err)
+	require.Equal(t, cursor, database.Cursor{int64(3), "foo", now, true, 1.5, []byte("bar")})
+}
+
+func TestKeysetTokenSignature(t *testing.T) {
+	ctrl := &KeysetController{signingKey: defaultSigningKey, checksum: 42}
+	token := ctrl.encodeToken(database.Cursor{"foo"}, false)
+
+	other := &KeysetController{signingKey: []byte("other-key"), checksum: 42}
+	_, err := other.decodeToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	_, err = ctrl.decodeToken("x" + token[1:])
+	require.True(t, errors.Is(err, ErrInvalidToken))
+}
+
+func TestKeysetSigningKeyOption(t *testing.T) {
+	ctrl := NewSQLKeyset(nil, FromToken(10, ""))
+	require.Len(t, ctrl.signingKey, 32)
+	require.Equal(t, ctrl.signingKey, defaultSigningKey)
+
+	ctrl = NewSQLKeyset(nil, FromToken(10, ""), WithSigningKey([]byte("foo")))
+	require.Equal(t, ctrl.signingKey, []byte("foo"))
+}
+
+func TestKeysetTokenChecksum(t *testing.T) {
+	ctrl := &KeysetController{signingKey: defaultSigningKey, checksum: 42}
+	token := ctrl.encodeToken(database.Cursor{"foo"}, false)
+
+	ctrl.checksum = 43
+	_, err := ctrl.decodeToken(token)
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+}
+
+func TestKeysetMovingBetweenPages(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase()
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		require.NoError(t, testings.Put(ctx, &testingModel{Code: fmt.Sprintf("foo-%d", i)}))
+	}
+
+	var page []*testingModel
+	ctrl := NewSQLKeyset(testings.Clone().Order("-code"), FromToken(3, ""))
+	require.NoError(t, ctrl.Fetch(ctx, &page))
+	require.Len(t, page, 3)
+	require.Equal(t, page[0].Code, "foo-7")
+	require.Equal(t, page[2].Code, "foo-5")
+	require.Empty(t, ctrl.PrevPageToken())
+	require.NotEmpty(t, ctrl.NextPageToken())
+
+	ctrl = NewSQLKeyset(testings.Clone().Order("-code"), FromToken(3, ctrl.NextPageToken()))
+	require.NoError(t, ctrl.Fetch(ctx, &page))
+	require.Len(t, page, 3)
+	require.Equal(t, page[0].Code, "foo-4")
+	require.Equal(t, page[2].Code, "foo-2")
+	require.NotEmpty(t, ctrl.PrevPageToken())
+
+	// Rows inserted in previous pages should not move the next ones.
+	require.NoError(t, testings.Put(ctx, &testingModel{Code: "foo-9"}))
+
+	next := ctrl.NextPageToken()
+	ctrl = NewSQLKeyset(testings.Clone().Order("-code"), FromToken(3, next))
+	require.NoError(t, ctrl.Fetch(ctx, &page))
+	require.Len(t, page, 2)
+	require.Equal(t, page[0].Code, "foo-1")
+	require.Equal(t, page[1].Code, "foo-0")
+	require.Empty(t, ctrl.NextPageToken())
+
+	ctrl = NewSQLKeyset(testings.Clone().Order("-code"), FromToken(3, ctrl.PrevPageToken()))
+	require.NoError(t, ctrl.Fetch(ctx, &page))
+	require.Len(t, page, 3)
+	require.Equal(t, page[0].Code, "foo-4")
+	require.Equal(t, page[2].Code, "foo-2")
+	require.NotEmpty(t, ctrl.PrevPageToken())
+	require.Equal(t, ctrl.NextPageToken(), next)
+}
+
+func TestKeysetChecksumMismatch(t *testing.T) {
+	initDatabase(t)
+	defer closeDatabase()
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, testings.Put(ctx, &testingModel{Code: fmt.Sprintf("foo-%d", i)}))
+	}
+
+	var page []*testingModel
+	ctrl := NewSQLKeyset(testings.Clone(), FromToken(2, ""))
+	require.NoError(t, ctrl.Fetch(ctx, &page))
+
+	ctrl = NewSQLKeyset(testings.Clone().Order("-code"), FromToken(2, ctrl.NextPageToken()))
+	require.True(t, errors.Is(ctrl.Fetch(ctx, &page), ErrChecksumMismatch))
+}
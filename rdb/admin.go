@@ -1,5 +1,12 @@
 package rdb
 
+import (
+	"math"
+	"time"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
 type AdminOperation interface {
 	URL() string
 	Body() interface{}
@@ -31,3 +38,64 @@ func DisableExpiration() *ConfigureExpirationOperation {
 func EnableExpiration() *ConfigureExpirationOperation {
 	return new(ConfigureExpirationOperation)
 }
+
+// ConfigureTimeSeriesOperation replaces the retention and rollup policies of the
+// time series of the database. Collections not configured in the operation lose
+// their previous policies.
+type ConfigureTimeSeriesOperation struct {
+	collections map[string]*api.TimeSeriesCollectionConfiguration
+}
+
+func ConfigureTimeSeries() *ConfigureTimeSeriesOperation {
+	return &ConfigureTimeSeriesOperation{
+		collections: make(map[string]*api.TimeSeriesCollectionConfiguration),
+	}
+}
+
+func (op *ConfigureTimeSeriesOperation) collection(name string) *api.TimeSeriesCollectionConfiguration {
+	if op.collections[name] == nil {
+		op.collections[name] = &api.TimeSeriesCollectionConfiguration{
+			RawPolicy: &api.TimeSeriesPolicy{
+				Name:          "rawpolicy",
+				RetentionTime: timeValue(0),
+			},
+		}
+	}
+	return op.collections[name]
+}
+
+// SetRawRetention keeps the raw entries of the time series of the collection only
+// during the retention time. A zero retention keeps them forever.
+func (op *ConfigureTimeSeriesOperation) SetRawRetention(collection string, retention time.Duration) {
+	op.collection(collection).RawPolicy.RetentionTime = timeValue(retention)
+}
+
+// AddRollup aggregates the entries of the time series of the collection in groups
+// of the aggregation time, keeping them during the retention time. A zero retention
+// keeps them forever. Each rollup aggregates the entries of the previous one.
+func (op *ConfigureTimeSeriesOperation) AddRollup(collection, name string, aggregation, retention time.Duration) {
+	aggregationTime := timeValue(aggregation)
+	config := op.collection(collection)
+	config.Policies = append(config.Policies, &api.TimeSeriesPolicy{
+		Name:            name,
+		AggregationTime: &aggregationTime,
+		RetentionTime:   timeValue(retention),
+	})
+}
+
+func (op *ConfigureTimeSeriesOperation) URL() string {
+	return "/admin/timeseries/config"
+}
+
+func (op *ConfigureTimeSeriesOperation) Body() interface{} {
+	return &api.TimeSeriesConfiguration{
+		Collections: op.collections,
+	}
+}
+
+func timeValue(d time.Duration) api.TimeValue {
+	if d == 0 {
+		return api.TimeValue{Value: math.MaxInt32, Unit: "None"}
+	}
+	return api.TimeValue{Value: int64(d / time.Second), Unit: "Second"}
+}
@@ -1,6 +1,9 @@
 package api
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Database struct {
 	DatabaseName  string
@@ -59,6 +62,9 @@ type Results struct {
 	Results  []Result
 	Includes map[string]Result
 
+	// Ranges of the included time series by document ID and name.
+	TimeSeriesIncludes map[string]map[string][]*TimeSeriesRangeResult
+
 	// Only available in query results.
 	TotalResults     int64
 	SkippedResults   int64
@@ -102,9 +108,12 @@ func (result Result) ReadAsMetadata() ModelMetadata {
 }
 
 type BulkCommands struct {
-	Commands []BatchCommand
+	Commands        []BatchCommand
+	TransactionMode string `json:",omitempty"`
 }
 
+const TransactionModeClusterWide = "ClusterWide"
+
 type BatchCommand interface {
 	isBatchCommand()
 }
@@ -142,6 +151,26 @@ type DeletePrefixCommand struct {
 
 func (cmd *DeletePrefixCommand) isBatchCommand() {}
 
+type PatchCommand struct {
+	ID           string  `json:"Id"`
+	ChangeVector *string `json:",omitempty"`
+	Patch        *PatchScript
+
+	// Always set to "PATCH"
+	Type string
+}
+
+func (cmd *PatchCommand) isBatchCommand() {}
+
+type PatchScript struct {
+	Script string
+	Values map[string]interface{}
+}
+
+type DocumentPatch struct {
+	Patch *PatchScript
+}
+
 type Query struct {
 	Query                         string
 	QueryParameters               map[string]interface{} `json:",omitempty"`
@@ -215,6 +244,286 @@ type CounterOperation struct {
 	Type        CounterOperationType
 }
 
+type TimeSeriesOperation struct {
+	Name    string
+	Appends []*TimeSeriesAppend `json:",omitempty"`
+	Deletes []*TimeSeriesDelete `json:",omitempty"`
+}
+
+type TimeSeriesAppend struct {
+	Timestamp string
+	Values    []float64
+	Tag       string `json:",omitempty"`
+}
+
+type TimeSeriesDelete struct {
+	From *string
+	To   *string
+}
+
+type TimeSeriesRangeResult struct {
+	From         *string
+	To           *string
+	Entries      []*TimeSeriesEntry
+	TotalResults int64
+}
+
+type TimeSeriesEntry struct {
+	Timestamp string
+	Tag       string
+	Values    []float64
+	IsRollup  bool
+}
+
+type TimeSeriesConfiguration struct {
+	Collections map[string]*TimeSeriesCollectionConfiguration
+}
+
+type TimeSeriesCollectionConfiguration struct {
+	Disabled  bool
+	Policies  []*TimeSeriesPolicy
+	RawPolicy *TimeSeriesPolicy
+}
+
+type TimeSeriesPolicy struct {
+	Name            string
+	RetentionTime   TimeValue
+	AggregationTime *TimeValue `json:",omitempty"`
+}
+
+type TimeValue struct {
+	Value int64
+	Unit  string
+}
+
 type DocsRequest struct {
 	IDs []string `json:"Ids"`
 }
+
+type SubscriptionCreation struct {
+	Name         string
+	Query        string
+	ChangeVector string `json:",omitempty"`
+	Disabled     bool   `json:",omitempty"`
+}
+
+type SubscriptionCreationResult struct {
+	Name string
+}
+
+type TCPInfo struct {
+	URL         string `json:"Url"`
+	Certificate string
+}
+
+const (
+	TCPOperationSubscription = "Subscription"
+
+	TCPStatusOk = "Ok"
+)
+
+type TCPConnectionHeader struct {
+	DatabaseName     string
+	Operation        string
+	OperationVersion int64
+}
+
+type TCPConnectionHeaderResponse struct {
+	Status  string
+	Message string
+	Version int64
+}
+
+type SubscriptionConnectionOptions struct {
+	SubscriptionName                string
+	Strategy                        string
+	MaxDocsPerBatch                 int64
+	TimeToWaitBeforeConnectionRetry Duration
+}
+
+const (
+	SubscriptionMessageConnectionStatus = "ConnectionStatus"
+	SubscriptionMessageData             = "Data"
+	SubscriptionMessageIncludes         = "Includes"
+	SubscriptionMessageEndOfBatch       = "EndOfBatch"
+	SubscriptionMessageConfirm          = "Confirm"
+	SubscriptionMessageError            = "Error"
+
+	SubscriptionStatusAccepted = "Accepted"
+	SubscriptionStatusInUse    = "InUse"
+	SubscriptionStatusClosed   = "Closed"
+	SubscriptionStatusNotFound = "NotFound"
+	SubscriptionStatusInvalid  = "Invalid"
+)
+
+type SubscriptionServerMessage struct {
+	Type      string
+	Status    string
+	Message   string
+	Exception string
+	Data      Result
+}
+
+const SubscriptionClientAcknowledge = "Acknowledge"
+
+type SubscriptionClientMessage struct {
+	Type         string
+	ChangeVector string
+}
+
+type Attachment struct {
+	Name        string
+	Hash        string
+	ContentType string
+	Size        int64
+}
+
+type AttachmentPutCommand struct {
+	ID           string `json:"Id"`
+	Name         string
+	ContentType  string
+	ChangeVector *string
+
+	// Always set to "AttachmentPUT"
+	Type string
+}
+
+func (cmd *AttachmentPutCommand) isBatchCommand() {}
+
+type AttachmentDeleteCommand struct {
+	ID           string `json:"Id"`
+	Name         string
+	ChangeVector *string
+
+	// Always set to "AttachmentDELETE"
+	Type string
+}
+
+func (cmd *AttachmentDeleteCommand) isBatchCommand() {}
+
+type ChangesCommand struct {
+	CommandID int64 `json:"CommandId"`
+	Command   string
+	Param     string `json:",omitempty"`
+}
+
+const (
+	ChangesMessageDocument = "DocumentChange"
+	ChangesMessageIndex    = "IndexChange"
+)
+
+type ChangesMessage struct {
+	Type      string
+	Value     json.RawMessage
+	CommandID int64 `json:"CommandId"`
+	Exception string
+}
+
+type DocumentChange struct {
+	Type           string
+	ID             string `json:"Id"`
+	CollectionName string
+	ChangeVector   string
+}
+
+type IndexChange struct {
+	Type string
+	Name string
+}
+
+type NextOperationID struct {
+	ID int64 `json:"Id"`
+}
+
+type CompareExchangeValue struct {
+	Object interface{}
+}
+
+type CompareExchangeResults struct {
+	Results []*CompareExchangeResult
+}
+
+type CompareExchangeResult struct {
+	Key   string
+	Index int64
+	Value struct {
+		Object json.RawMessage
+	}
+}
+
+type CompareExchangeOperationResult struct {
+	Index      int64
+	Successful bool
+}
+
+type CompareExchangePutCommand struct {
+	Key      string
+	Index    int64
+	Document *CompareExchangeValue
+
+	// Always set to "CompareExchangePUT"
+	Type string
+}
+
+func (cmd *CompareExchangePutCommand) isBatchCommand() {}
+
+type CompareExchangeDeleteCommand struct {
+	Key   string
+	Index int64
+
+	// Always set to "CompareExchangeDELETE"
+	Type string
+}
+
+func (cmd *CompareExchangeDeleteCommand) isBatchCommand() {}
+
+type FacetOptions struct {
+	Start                 int64  `json:",omitempty"`
+	PageSize              int64  `json:",omitempty"`
+	TermSortMode          string `json:",omitempty"`
+	IncludeRemainingTerms bool   `json:",omitempty"`
+}
+
+type FacetResults struct {
+	Results []*FacetResult
+}
+
+type FacetResult struct {
+	Name                string
+	Values              []*FacetValue
+	RemainingTerms      []string
+	RemainingTermsCount int64
+	RemainingHits       int64
+}
+
+type FacetValue struct {
+	Name    string
+	Range   string
+	Count   int64
+	Sum     *float64
+	Max     *float64
+	Min     *float64
+	Average *float64
+}
+
+type Topology struct {
+	Nodes []*ServerNode
+	Etag  int64
+}
+
+type ServerNode struct {
+	URL        string `json:"Url"`
+	ClusterTag string
+	Database   string
+	ServerRole string
+}
+
+type IndexStatsResults struct {
+	Results []*IndexStats
+}
+
+type IndexStats struct {
+	Name    string
+	IsStale bool
+	State   string
+}
@@ -0,0 +1,264 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Attachment describes a binary file stored alongside a document.
+type Attachment = api.Attachment
+
+type putAttachmentAction struct {
+	id          string
+	name        string
+	contentType string
+	content     io.Reader
+}
+
+func (action *putAttachmentAction) batchCommand() (api.BatchCommand, error) {
+	return &api.AttachmentPutCommand{
+		ID:          action.id,
+		Name:        action.name,
+		ContentType: action.contentType,
+		Type:        "AttachmentPUT",
+	}, nil
+}
+
+type deleteAttachmentAction struct {
+	id   string
+	name string
+}
+
+func (action *deleteAttachmentAction) batchCommand() (api.BatchCommand, error) {
+	return &api.AttachmentDeleteCommand{
+		ID:   action.id,
+		Name: action.name,
+		Type: "AttachmentDELETE",
+	}, nil
+}
+
+// PutAttachment stores the content as an attachment of the document with the name.
+// If there is a session in the context it will be sent in the next SaveChanges
+// call along with the rest of the actions, so a document and its attachments can
+// be stored together. The content will not be read until then.
+func (collection *Collection) PutAttachment(ctx context.Context, id, name, contentType string, content io.Reader) error {
+	if id == "" || name == "" {
+		return errors.Errorf("document id and attachment name are required")
+	}
+
+	action := &putAttachmentAction{
+		id:          id,
+		name:        name,
+		contentType: contentType,
+		content:     content,
+	}
+
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		ctx, sess := collection.db.NewSession(ctx)
+		sess.actions = append(sess.actions, action)
+		return errors.Trace(sess.SaveChanges(ctx))
+	}
+
+	sess.actions = append(sess.actions, action)
+	return nil
+}
+
+// DeleteAttachment removes an attachment of the document. If there is a session in
+// the context it will be removed in the next SaveChanges call.
+func (collection *Collection) DeleteAttachment(ctx context.Context, id, name string) error {
+	if id == "" || name == "" {
+		return errors.Errorf("document id and attachment name are required")
+	}
+
+	action := &deleteAttachmentAction{
+		id:   id,
+		name: name,
+	}
+
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		ctx, sess := collection.db.NewSession(ctx)
+		sess.actions = append(sess.actions, action)
+		return errors.Trace(sess.SaveChanges(ctx))
+	}
+
+	sess.actions = append(sess.actions, action)
+	return nil
+}
+
+// GetAttachment opens the content of an attachment of the document to read it as
+// it arrives from the server. The caller should close the returned reader. If the
+// attachment does not exist it returns ErrNoSuchEntity.
+func (collection *Collection) GetAttachment(ctx context.Context, id, name string) (io.ReadCloser, *Attachment, error) {
+	params := map[string]interface{}{
+		"id":   id,
+		"name": name,
+	}
+	r, err := collection.conn.buildGET(collection.conn.endpoint("attachments"), params)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	resp, err := collection.conn.sendStreamRequest(ctx, r)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		attachment := &Attachment{
+			Name:        name,
+			Hash:        resp.Header.Get("Attachment-Hash"),
+			ContentType: resp.Header.Get("Content-Type"),
+			Size:        resp.ContentLength,
+		}
+		if size := resp.Header.Get("Attachment-Size"); size != "" {
+			attachment.Size, err = strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				_ = resp.Body.Close()
+				return nil, nil, errors.Trace(err)
+			}
+		}
+		return resp.Body, attachment, nil
+	case http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, nil, newNoSuchEntityError("attachment %q of id %q", name, id)
+	default:
+		err := NewUnexpectedStatusError(r, resp)
+		_ = resp.Body.Close()
+		return nil, nil, err
+	}
+}
+
+// ListAttachments returns the attachments of the document without their content.
+// If the document does not exist it returns ErrNoSuchEntity.
+func (collection *Collection) ListAttachments(ctx context.Context, id string) ([]*Attachment, error) {
+	if id == "" {
+		return nil, newNoSuchEntityError("empty id")
+	}
+
+	params := map[string]interface{}{
+		"id":           id,
+		"metadataOnly": "true",
+	}
+	r, err := collection.conn.buildGET(collection.conn.endpoint("docs"), params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := collection.conn.sendRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		results := new(struct {
+			Results []struct {
+				Metadata struct {
+					Attachments []*Attachment `json:"@attachments"`
+				} `json:"@metadata"`
+			}
+		})
+		if err := json.NewDecoder(resp.Body).Decode(results); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(results.Results) == 0 {
+			return nil, newNoSuchEntityError("id %q", id)
+		}
+		return results.Results[0].Metadata.Attachments, nil
+	case http.StatusNotFound:
+		return nil, newNoSuchEntityError("id %q", id)
+	default:
+		return nil, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+func (action *putAttachmentAction) send(ctx context.Context, conn *connection) error {
+	params := map[string]string{
+		"id":   action.id,
+		"name": action.name,
+	}
+	if action.contentType != "" {
+		params["contentType"] = action.contentType
+	}
+	r, err := conn.buildStream(http.MethodPut, conn.endpoint("attachments"), params, "", action.content)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := conn.sendStreamRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusNotFound:
+		return newNoSuchEntityError("id %q", action.id)
+	case http.StatusConflict:
+		return errors.Trace(ErrConcurrentTransaction)
+	default:
+		return NewUnexpectedStatusError(r, resp)
+	}
+}
+
+func (action *deleteAttachmentAction) send(ctx context.Context, conn *connection) error {
+	params := map[string]string{
+		"id":   action.id,
+		"name": action.name,
+	}
+	r, err := conn.buildDELETE(conn.endpoint("attachments"), params)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusConflict:
+		return errors.Trace(ErrConcurrentTransaction)
+	default:
+		return NewUnexpectedStatusError(r, resp)
+	}
+}
+
+// writeBatchMultipart writes the batch commands followed by the content of the
+// attachments in the same order of their commands, as the server expects them
+// when uploading attachments in a batch.
+func writeBatchMultipart(w *multipart.Writer, batch *api.BulkCommands, streams []io.Reader) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := json.NewEncoder(part).Encode(batch); err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, stream := range streams {
+		part, err := w.CreatePart(textproto.MIMEHeader{"Command-Type": {"AttachmentStream"}})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := io.Copy(part, stream); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return errors.Trace(w.Close())
+}
@@ -0,0 +1,100 @@
+package rdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+func TestWriteBatchMultipart(t *testing.T) {
+	action := &putAttachmentAction{
+		id:          "foo-queries/1",
+		name:        "foo.txt",
+		contentType: "text/plain",
+		content:     strings.NewReader("foo content"),
+	}
+	cmd, err := action.batchCommand()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	batch := &api.BulkCommands{Commands: []api.BatchCommand{cmd}}
+	require.NoError(t, writeBatchMultipart(w, batch, []io.Reader{action.content}))
+
+	reader := multipart.NewReader(&buf, w.Boundary())
+	part, err := reader.NextPart()
+	require.NoError(t, err)
+	commands := new(struct {
+		Commands []*api.AttachmentPutCommand
+	})
+	require.NoError(t, json.NewDecoder(part).Decode(commands))
+	require.Len(t, commands.Commands, 1)
+	require.Equal(t, commands.Commands[0].Type, "AttachmentPUT")
+	require.Equal(t, commands.Commands[0].Name, "foo.txt")
+
+	part, err = reader.NextPart()
+	require.NoError(t, err)
+	require.Equal(t, part.Header.Get("Command-Type"), "AttachmentStream")
+	content, err := io.ReadAll(part)
+	require.NoError(t, err)
+	require.Equal(t, string(content), "foo content")
+
+	_, err = reader.NextPart()
+	require.Equal(t, err, io.EOF)
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+
+	require.NoError(t, collection.PutAttachment(ctx, "foo-queries/1", "foo.txt", "text/plain", strings.NewReader("foo content")))
+
+	r, attachment, err := collection.GetAttachment(ctx, "foo-queries/1", "foo.txt")
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, string(content), "foo content")
+	require.Equal(t, attachment.ContentType, "text/plain")
+	require.EqualValues(t, attachment.Size, 11)
+	require.NotEmpty(t, attachment.Hash)
+
+	attachments, err := collection.ListAttachments(ctx, "foo-queries/1")
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	require.Equal(t, attachments[0].Name, "foo.txt")
+
+	require.NoError(t, collection.DeleteAttachment(ctx, "foo-queries/1", "foo.txt"))
+
+	_, _, err = collection.GetAttachment(ctx, "foo-queries/1", "foo.txt")
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+}
+
+func TestAttachmentsSession(t *testing.T) {
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+	ctx, sess := db.NewSession(context.Background())
+
+	foo := &FooQueryModel{
+		ID:          "foo-queries/4",
+		DisplayName: "Foo4",
+	}
+	require.NoError(t, collection.Put(ctx, foo))
+	require.NoError(t, collection.PutAttachment(ctx, foo.ID, "foo.txt", "text/plain", strings.NewReader("foo content")))
+	require.NoError(t, collection.PutAttachment(ctx, foo.ID, "bar.txt", "text/plain", strings.NewReader("bar content")))
+	require.NoError(t, sess.SaveChanges(ctx))
+
+	attachments, err := collection.ListAttachments(context.Background(), foo.ID)
+	require.NoError(t, err)
+	require.Len(t, attachments, 2)
+}
@@ -0,0 +1,169 @@
+package rdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// BulkInsert streams models to the server in a single long-lived request. It is
+// much faster than a session to import or seed large amounts of documents because
+// they are not accumulated in memory.
+type BulkInsert struct {
+	mu      sync.Mutex
+	pw      *io.PipeWriter
+	w       *bufio.Writer
+	enc     *json.Encoder
+	started bool
+	closed  bool
+	done    chan error
+}
+
+// BulkInsert opens a new bulk insert operation. Call Close at the end to wait
+// for the server to store all the models and check the final result. Cancelling
+// the context aborts the operation.
+func (db *Database) BulkInsert(ctx context.Context) (*BulkInsert, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	id, err := db.conn.nextOperationID(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	pr, pw := io.Pipe()
+	r, err := db.conn.buildStream(http.MethodPost, db.conn.endpoint("bulk_insert"), map[string]string{"id": strconv.FormatInt(id, 10)}, "application/json; charset=UTF-8", pr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	bulk := &BulkInsert{
+		pw:   pw,
+		w:    bufio.NewWriter(pw),
+		done: make(chan error, 1),
+	}
+	bulk.enc = json.NewEncoder(bulk.w)
+
+	conn := db.conn
+	go func() {
+		err := bulk.send(ctx, conn, r)
+		// Unblock the writes if the server finishes before reading all of them.
+		pr.CloseWithError(errors.Errorf("bulk insert finished: %v", err))
+		bulk.done <- err
+	}()
+
+	return bulk, nil
+}
+
+func (bulk *BulkInsert) send(ctx context.Context, conn *connection, r *http.Request) error {
+	resp, err := conn.sendStreamRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return NewUnexpectedStatusError(r, resp)
+	}
+	return nil
+}
+
+// Store sends a new model to the server. The model should have an ID, the
+// collection and expiration of the model will be stored in its metadata too.
+// Errors of the server are returned by Close.
+func (bulk *BulkInsert) Store(model Model) error {
+	bulk.mu.Lock()
+	defer bulk.mu.Unlock()
+
+	if bulk.closed {
+		return errors.Errorf("bulk insert already closed")
+	}
+
+	id, err := getModelID(model)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if id == "" {
+		return errors.Errorf("models stored with a bulk insert should have an ID")
+	}
+	serialized, err := serializeModel(model)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	sep := ","
+	if !bulk.started {
+		sep = "["
+		bulk.started = true
+	}
+	if _, err := bulk.w.WriteString(sep); err != nil {
+		return errors.Trace(err)
+	}
+	cmd := &api.PutCommand{
+		ID:       id,
+		Document: serialized,
+		Type:     "PUT",
+	}
+	if err := bulk.enc.Encode(cmd); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// Close finishes sending models and waits until the server stores all of them.
+// It returns any error produced during the whole operation.
+func (bulk *BulkInsert) Close() error {
+	bulk.mu.Lock()
+	defer bulk.mu.Unlock()
+
+	if bulk.closed {
+		return errors.Errorf("bulk insert already closed")
+	}
+	bulk.closed = true
+
+	end := "]"
+	if !bulk.started {
+		end = "[]"
+	}
+	_, writeErr := bulk.w.WriteString(end)
+	if writeErr == nil {
+		writeErr = bulk.w.Flush()
+	}
+	bulk.pw.Close()
+
+	if err := <-bulk.done; err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(writeErr)
+}
+
+func (conn *connection) nextOperationID(ctx context.Context) (int64, error) {
+	r, err := conn.buildGET(conn.endpoint("operations/next-operation-id"), nil)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	resp, err := conn.sendRequest(ctx, r)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, NewUnexpectedStatusError(r, resp)
+	}
+
+	op := new(api.NextOperationID)
+	if err := json.NewDecoder(resp.Body).Decode(op); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return op.ID, nil
+}
@@ -0,0 +1,92 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+func TestBulkInsertStream(t *testing.T) {
+	var commands []*api.PutCommand
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/databases/foo-db/operations/next-operation-id":
+			_, _ = w.Write([]byte(`{"Id": 7}`))
+		case "/databases/foo-db/bulk_insert":
+			if r.URL.Query().Get("id") != "7" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&commands); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+	}))
+
+	bulk, err := db.BulkInsert(context.Background())
+	require.NoError(t, err)
+
+	foo := &FooQueryModel{
+		ID:          "foo-queries/1",
+		DisplayName: "Foo1",
+	}
+	foo.Expire(time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, bulk.Store(foo))
+	require.NoError(t, bulk.Store(&FooQueryModel{ID: "foo-queries/2", DisplayName: "Foo2"}))
+	require.Error(t, bulk.Store(new(FooQueryModel)))
+	require.NoError(t, bulk.Close())
+
+	require.Len(t, commands, 2)
+	require.Equal(t, commands[0].ID, "foo-queries/1")
+	require.Equal(t, commands[0].Type, "PUT")
+	doc := commands[0].Document.(map[string]interface{})
+	require.Equal(t, doc["DisplayName"], "Foo1")
+	require.Equal(t, doc["@metadata"], map[string]interface{}{
+		"@collection": "FooQueryModels",
+		"@expires":    "2020-01-02T03:04:05.0000000Z",
+	})
+	require.Equal(t, commands[1].ID, "foo-queries/2")
+}
+
+func TestBulkInsertServerError(t *testing.T) {
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/databases/foo-db/operations/next-operation-id":
+			_, _ = w.Write([]byte(`{"Id": 7}`))
+		case "/databases/foo-db/bulk_insert":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"Type": "Raven.Server.Exception", "Message": "bulk insert failed"}`))
+		}
+	}))
+
+	bulk, err := db.BulkInsert(context.Background())
+	require.NoError(t, err)
+	_ = bulk.Store(&FooQueryModel{ID: "foo-queries/1"})
+
+	err = bulk.Close()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "bulk insert failed")
+}
+
+func TestBulkInsert(t *testing.T) {
+	ctx := context.Background()
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+
+	bulk, err := db.BulkInsert(ctx)
+	require.NoError(t, err)
+	require.NoError(t, bulk.Store(&FooQueryModel{ID: "foo-queries/4", DisplayName: "Foo4"}))
+	require.NoError(t, bulk.Store(&FooQueryModel{ID: "foo-queries/5", DisplayName: "Foo5"}))
+	require.NoError(t, bulk.Close())
+
+	var result *FooQueryModel
+	require.NoError(t, collection.Get(ctx, "foo-queries/5", &result))
+	require.Equal(t, result.DisplayName, "Foo5")
+}
@@ -0,0 +1,381 @@
+package rdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"golang.org/x/net/websocket"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+type DocumentChangeType string
+
+const (
+	DocumentChangePut    = DocumentChangeType("Put")
+	DocumentChangeDelete = DocumentChangeType("Delete")
+)
+
+// DocumentChange is sent when a watched document is stored or deleted.
+type DocumentChange struct {
+	Type         DocumentChangeType
+	ID           string
+	Collection   string
+	ChangeVector string
+}
+
+// IndexChange is sent when something happens to a watched index. Type is the name
+// of the event in RavenDB, e.g. "BatchCompleted" or "IndexRemoved".
+type IndexChange struct {
+	Type string
+	Name string
+}
+
+// Changes receives live notifications from the server about the changes of the
+// documents and indexes of the database.
+type Changes struct {
+	conn   *connection
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	ws          *websocket.Conn
+	nextCommand int64
+	watchers    []*changesWatcher
+	closed      bool
+}
+
+type changesWatcher struct {
+	command string
+	param   string
+	docs    chan DocumentChange
+	indexes chan IndexChange
+}
+
+func (watcher *changesWatcher) matchDocument(change DocumentChange) bool {
+	switch watcher.command {
+	case "watch-doc":
+		return strings.EqualFold(change.ID, watcher.param)
+	case "watch-prefix":
+		return strings.HasPrefix(strings.ToLower(change.ID), strings.ToLower(watcher.param))
+	case "watch-collection":
+		return strings.EqualFold(change.Collection, watcher.param)
+	}
+	return false
+}
+
+func (watcher *changesWatcher) matchIndex(change IndexChange) bool {
+	return watcher.command == "watch-index" && change.Name == watcher.param
+}
+
+func (watcher *changesWatcher) close() {
+	if watcher.docs != nil {
+		close(watcher.docs)
+	}
+	if watcher.indexes != nil {
+		close(watcher.indexes)
+	}
+}
+
+// Changes opens a connection to receive notifications of the changes in the
+// database. If the connection is lost it reconnects automatically and watches
+// again the same documents and indexes; changes that happen while disconnected
+// won't be notified.
+//
+// The connection is closed, and all the channels with it, when the context is
+// cancelled or Close is called. Read the channels continuously, the notifications
+// will wait until they are received.
+func (db *Database) Changes(ctx context.Context) (*Changes, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	changes := &Changes{
+		conn:   db.conn,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	ws, err := changes.connect()
+	if err != nil {
+		cancel()
+		return nil, errors.Trace(err)
+	}
+	go changes.run(ws)
+
+	return changes, nil
+}
+
+// ForDocument notifies the changes of a single document.
+func (changes *Changes) ForDocument(id string) <-chan DocumentChange {
+	return changes.watchDocuments("watch-doc", id)
+}
+
+// ForDocumentsStartingWith notifies the changes of the documents whose ID starts
+// with the prefix.
+func (changes *Changes) ForDocumentsStartingWith(prefix string) <-chan DocumentChange {
+	return changes.watchDocuments("watch-prefix", prefix)
+}
+
+// ForCollection notifies the changes of all the documents of the collection.
+func (changes *Changes) ForCollection(golden Model) <-chan DocumentChange {
+	return changes.watchDocuments("watch-collection", golden.Collection())
+}
+
+// ForIndex notifies the changes of the index.
+func (changes *Changes) ForIndex(name string) <-chan IndexChange {
+	watcher := &changesWatcher{
+		command: "watch-index",
+		param:   name,
+		indexes: make(chan IndexChange, 16),
+	}
+	changes.watch(watcher)
+	return watcher.indexes
+}
+
+func (changes *Changes) watchDocuments(command, param string) <-chan DocumentChange {
+	watcher := &changesWatcher{
+		command: command,
+		param:   param,
+		docs:    make(chan DocumentChange, 16),
+	}
+	changes.watch(watcher)
+	return watcher.docs
+}
+
+func (changes *Changes) watch(watcher *changesWatcher) {
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	if changes.closed {
+		watcher.close()
+		return
+	}
+
+	changes.watchers = append(changes.watchers, watcher)
+
+	// If it fails the connection is broken and the command will be sent again
+	// after reconnecting.
+	_ = changes.sendCommand(watcher.command, watcher.param)
+}
+
+// Close stops receiving notifications and closes all the channels.
+func (changes *Changes) Close() error {
+	changes.cancel()
+	<-changes.done
+	return nil
+}
+
+// sendCommand should be called with the lock held.
+func (changes *Changes) sendCommand(command, param string) error {
+	if changes.ws == nil {
+		return nil
+	}
+
+	changes.nextCommand++
+	cmd := &api.ChangesCommand{
+		CommandID: changes.nextCommand,
+		Command:   command,
+		Param:     param,
+	}
+	return errors.Trace(websocket.JSON.Send(changes.ws, cmd))
+}
+
+func (changes *Changes) connect() (*websocket.Conn, error) {
+	changes.conn.configmux.RLock()
+	u := *changes.conn.base
+	changes.conn.configmux.RUnlock()
+
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = changes.conn.endpoint("changes")
+
+	config, err := websocket.NewConfig(u.String(), "http://localhost/")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config.TlsConfig = changes.conn.tlsConfig
+	config.Dialer = &net.Dialer{Timeout: 30 * time.Second}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	changes.ws = ws
+	for _, watcher := range changes.watchers {
+		if err := changes.sendCommand(watcher.command, watcher.param); err != nil {
+			_ = ws.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return ws, nil
+}
+
+func (changes *Changes) run(ws *websocket.Conn) {
+	defer close(changes.done)
+
+	go func() {
+		<-changes.ctx.Done()
+		changes.mu.Lock()
+		defer changes.mu.Unlock()
+		if changes.ws != nil {
+			_ = changes.ws.Close()
+		}
+	}()
+
+	for {
+		err := changes.read(ws)
+		if changes.ctx.Err() != nil {
+			break
+		}
+		slog.Warn("RavenDB changes connection lost, reconnecting", slog.String("error", err.Error()))
+
+		ws = changes.reconnect()
+		if ws == nil {
+			break
+		}
+	}
+
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	changes.closed = true
+	changes.ws = nil
+	for _, watcher := range changes.watchers {
+		watcher.close()
+	}
+}
+
+// reconnect tries to connect again with an exponential backoff. It returns nil
+// if the context is cancelled before.
+func (changes *Changes) reconnect() *websocket.Conn {
+	changes.mu.Lock()
+	changes.ws = nil
+	changes.mu.Unlock()
+
+	backoff := 1 * time.Second
+	for {
+		select {
+		case <-changes.ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		ws, err := changes.connect()
+		if err == nil {
+			// The context could have been cancelled while connecting.
+			if changes.ctx.Err() != nil {
+				_ = ws.Close()
+				return nil
+			}
+			return ws
+		}
+		slog.Warn("Cannot reconnect to RavenDB changes", slog.String("error", err.Error()))
+
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+func (changes *Changes) read(ws *websocket.Conn) error {
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return errors.Trace(err)
+		}
+
+		// The server sends arrays of messages, and in some versions single messages.
+		var messages []*api.ChangesMessage
+		if bytes.HasPrefix(bytes.TrimSpace(msg), []byte("[")) {
+			if err := json.Unmarshal(msg, &messages); err != nil {
+				return errors.Trace(err)
+			}
+		} else {
+			single := new(api.ChangesMessage)
+			if err := json.Unmarshal(msg, single); err != nil {
+				return errors.Trace(err)
+			}
+			messages = append(messages, single)
+		}
+
+		for _, message := range messages {
+			if err := changes.dispatch(message); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+}
+
+func (changes *Changes) dispatch(message *api.ChangesMessage) error {
+	if message.Exception != "" {
+		return errors.Errorf("changes error: %s", message.Exception)
+	}
+
+	changes.mu.Lock()
+	watchers := make([]*changesWatcher, len(changes.watchers))
+	copy(watchers, changes.watchers)
+	changes.mu.Unlock()
+
+	switch message.Type {
+	case api.ChangesMessageDocument:
+		value := new(api.DocumentChange)
+		if err := json.Unmarshal(message.Value, value); err != nil {
+			return errors.Trace(err)
+		}
+		change := DocumentChange{
+			Type:         DocumentChangeType(value.Type),
+			ID:           value.ID,
+			Collection:   value.CollectionName,
+			ChangeVector: value.ChangeVector,
+		}
+		for _, watcher := range watchers {
+			if watcher.docs == nil || !watcher.matchDocument(change) {
+				continue
+			}
+			select {
+			case watcher.docs <- change:
+			case <-changes.ctx.Done():
+				return nil
+			}
+		}
+
+	case api.ChangesMessageIndex:
+		value := new(api.IndexChange)
+		if err := json.Unmarshal(message.Value, value); err != nil {
+			return errors.Trace(err)
+		}
+		change := IndexChange{
+			Type: value.Type,
+			Name: value.Name,
+		}
+		for _, watcher := range watchers {
+			if watcher.indexes == nil || !watcher.matchIndex(change) {
+				continue
+			}
+			select {
+			case watcher.indexes <- change:
+			case <-changes.ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package rdb
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+func TestChangesReconnect(t *testing.T) {
+	var connections int32
+	db := newFakeDatabase(t, websocket.Handler(func(ws *websocket.Conn) {
+		n := atomic.AddInt32(&connections, 1)
+
+		cmd := new(api.ChangesCommand)
+		if err := websocket.JSON.Receive(ws, cmd); err != nil || cmd.Command != "watch-doc" {
+			return
+		}
+		msg := `[
+			{"Type": "DocumentChange", "Value": {"Type": "Put", "Id": "foo-queries/2", "CollectionName": "FooQueryModels", "ChangeVector": "A:1"}},
+			{"Type": "DocumentChange", "Value": {"Type": "Put", "Id": "foo-queries/1", "CollectionName": "FooQueryModels", "ChangeVector": "A:2"}}
+		]`
+		if n > 1 {
+			msg = `{"Type": "DocumentChange", "Value": {"Type": "Delete", "Id": "foo-queries/1", "CollectionName": "FooQueryModels", "ChangeVector": "A:3"}}`
+		}
+		if err := websocket.Message.Send(ws, msg); err != nil {
+			return
+		}
+
+		// Keep the second connection open until the client closes it.
+		if n > 1 {
+			var discard []byte
+			_ = websocket.Message.Receive(ws, &discard)
+		}
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	changes, err := db.Changes(ctx)
+	require.NoError(t, err)
+
+	ch := changes.ForDocument("foo-queries/1")
+
+	change := <-ch
+	require.Equal(t, change, DocumentChange{
+		Type:         DocumentChangePut,
+		ID:           "foo-queries/1",
+		Collection:   "FooQueryModels",
+		ChangeVector: "A:2",
+	})
+
+	change = <-ch
+	require.Equal(t, change.Type, DocumentChangeDelete)
+	require.Equal(t, change.ChangeVector, "A:3")
+
+	require.NoError(t, changes.Close())
+	_, ok := <-ch
+	require.False(t, ok)
+}
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+
+	changes, err := db.Changes(ctx)
+	require.NoError(t, err)
+	defer changes.Close()
+
+	ch := changes.ForCollection(new(FooQueryModel))
+	time.Sleep(200 * time.Millisecond)
+
+	foo := &FooQueryModel{
+		ID:          "foo-queries/4",
+		DisplayName: "Foo4",
+	}
+	require.NoError(t, collection.Put(ctx, foo))
+
+	change := <-ch
+	require.Equal(t, change.Type, DocumentChangePut)
+	require.Equal(t, change.ID, "foo-queries/4")
+}
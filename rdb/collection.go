@@ -84,6 +84,7 @@ func (collection *Collection) Get(ctx context.Context, id string, dest interface
 		"id":      id,
 		"include": applyModelIncludes(opts...),
 	}
+	timeSeriesIncludeParams(resolveIncludes(opts...), params)
 	r, err := collection.conn.buildGET(collection.conn.endpoint("docs"), params)
 	if err != nil {
 		return errors.Trace(err)
@@ -101,6 +102,9 @@ func (collection *Collection) Get(ctx context.Context, id string, dest interface
 			return errors.Trace(err)
 		}
 		sess.mergeIncludes(results.Includes)
+		if err := sess.mergeTimeSeries(results.TimeSeriesIncludes); err != nil {
+			return errors.Trace(err)
+		}
 		model, err := createModel(dest, results.Results[0])
 		if err != nil {
 			return errors.Trace(err)
@@ -117,6 +121,9 @@ func (collection *Collection) Get(ctx context.Context, id string, dest interface
 		} else if len(collection.enforcers) > 0 {
 			return errors.Errorf("cannot enforce non-model entities")
 		}
+		if err := sess.track(dest); err != nil {
+			return errors.Trace(err)
+		}
 	case http.StatusNotFound:
 		return newNoSuchEntityError("id %q", id)
 	default:
@@ -181,6 +188,7 @@ func (collection *Collection) GetMulti(ctx context.Context, ids []string, dest i
 	params := map[string]interface{}{
 		"include": applyModelIncludes(opts...),
 	}
+	timeSeriesIncludeParams(resolveIncludes(opts...), params)
 	body := &api.DocsRequest{IDs: ids}
 	r, err := collection.conn.buildPOST(collection.conn.endpoint("docs"), params, body)
 	if err != nil {
@@ -199,6 +207,9 @@ func (collection *Collection) GetMulti(ctx context.Context, ids []string, dest i
 			return errors.Trace(err)
 		}
 		sess.mergeIncludes(results.Includes)
+		if err := sess.mergeTimeSeries(results.TimeSeriesIncludes); err != nil {
+			return errors.Trace(err)
+		}
 
 		merr := make(MultiError, len(ids))
 		slice := reflect.MakeSlice(rt.Elem(), 0, len(ids))
@@ -222,6 +233,9 @@ func (collection *Collection) GetMulti(ctx context.Context, ids []string, dest i
 			} else if len(collection.enforcers) > 0 {
 				return errors.Errorf("cannot enforce non-model entities")
 			}
+			if err := sess.track(item.Interface()); err != nil {
+				return errors.Trace(err)
+			}
 			slice = reflect.Append(slice, item.Elem())
 		}
 		reflect.ValueOf(dest).Elem().Set(slice)
@@ -0,0 +1,169 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// CompareExchange is a cluster-wide value that can only be modified if it has not
+// changed since it was read. It is the only way to guarantee uniqueness in RavenDB,
+// e.g. reserving an email with a key like "emails/foo@example.com".
+type CompareExchange struct {
+	conn *connection
+	key  string
+}
+
+// CompareExchange returns an accessor for the value of the key.
+func (db *Database) CompareExchange(key string) *CompareExchange {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return &CompareExchange{
+		conn: db.conn,
+		key:  key,
+	}
+}
+
+// Get reads the value in dest, that should be a pointer, and returns its index. If
+// the key does not exist it returns ErrNoSuchEntity.
+func (cmpxchg *CompareExchange) Get(ctx context.Context, dest interface{}) (int64, error) {
+	r, err := cmpxchg.conn.buildGET(cmpxchg.conn.endpoint("cmpxchg"), map[string]interface{}{"key": cmpxchg.key})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	resp, err := cmpxchg.conn.sendRequest(ctx, r)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		results := new(api.CompareExchangeResults)
+		if err := json.NewDecoder(resp.Body).Decode(results); err != nil {
+			return 0, errors.Trace(err)
+		}
+		if len(results.Results) == 0 || results.Results[0] == nil {
+			return 0, newNoSuchEntityError("compare exchange key %q", cmpxchg.key)
+		}
+		if err := json.Unmarshal(results.Results[0].Value.Object, dest); err != nil {
+			return 0, errors.Trace(err)
+		}
+		return results.Results[0].Index, nil
+	case http.StatusNotFound:
+		return 0, newNoSuchEntityError("compare exchange key %q", cmpxchg.key)
+	default:
+		return 0, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+// Put stores the value only if the index of the key on the server is still the
+// same, and returns the new index. Use an index of 0 to create a new key only if it
+// does not exist yet. If the index does not match it returns ErrConcurrentTransaction.
+func (cmpxchg *CompareExchange) Put(ctx context.Context, value interface{}, index int64) (int64, error) {
+	params := map[string]string{
+		"key":   cmpxchg.key,
+		"index": strconv.FormatInt(index, 10),
+	}
+	r, err := cmpxchg.conn.buildPUT(cmpxchg.conn.endpoint("cmpxchg"), params, &api.CompareExchangeValue{Object: value})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return cmpxchg.send(ctx, r)
+}
+
+// Delete removes the key only if its index on the server is still the same. If
+// the index does not match it returns ErrConcurrentTransaction.
+func (cmpxchg *CompareExchange) Delete(ctx context.Context, index int64) error {
+	params := map[string]string{
+		"key":   cmpxchg.key,
+		"index": strconv.FormatInt(index, 10),
+	}
+	r, err := cmpxchg.conn.buildDELETE(cmpxchg.conn.endpoint("cmpxchg"), params)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = cmpxchg.send(ctx, r)
+	return errors.Trace(err)
+}
+
+func (cmpxchg *CompareExchange) send(ctx context.Context, r *http.Request) (int64, error) {
+	resp, err := cmpxchg.conn.sendRequest(ctx, r)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result := new(api.CompareExchangeOperationResult)
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return 0, errors.Trace(err)
+		}
+		if !result.Successful {
+			return 0, errors.Trace(ErrConcurrentTransaction)
+		}
+		return result.Index, nil
+	case http.StatusConflict:
+		return 0, errors.Trace(ErrConcurrentTransaction)
+	default:
+		return 0, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+type putCompareExchangeAction struct {
+	key   string
+	value interface{}
+	index int64
+}
+
+func (action *putCompareExchangeAction) batchCommand() (api.BatchCommand, error) {
+	return &api.CompareExchangePutCommand{
+		Key:      action.key,
+		Index:    action.index,
+		Document: &api.CompareExchangeValue{Object: action.value},
+		Type:     "CompareExchangePUT",
+	}, nil
+}
+
+type deleteCompareExchangeAction struct {
+	key   string
+	index int64
+}
+
+func (action *deleteCompareExchangeAction) batchCommand() (api.BatchCommand, error) {
+	return &api.CompareExchangeDeleteCommand{
+		Key:   action.key,
+		Index: action.index,
+		Type:  "CompareExchangeDELETE",
+	}, nil
+}
+
+// PutCompareExchange stores the value of the key in the next SaveChanges call,
+// atomically with the rest of the actions of the session. Use an index of 0 to
+// create a new key only if it does not exist yet. The session should be opened
+// with WithClusterWideTransaction.
+func (sess *Session) PutCompareExchange(key string, value interface{}, index int64) error {
+	if !sess.clusterWide {
+		return errors.Errorf("compare exchange values can only be stored in a cluster-wide session")
+	}
+	sess.actions = append(sess.actions, &putCompareExchangeAction{key, value, index})
+	return nil
+}
+
+// DeleteCompareExchange removes the key in the next SaveChanges call, atomically
+// with the rest of the actions of the session. The session should be opened with
+// WithClusterWideTransaction.
+func (sess *Session) DeleteCompareExchange(key string, index int64) error {
+	if !sess.clusterWide {
+		return errors.Errorf("compare exchange values can only be deleted in a cluster-wide session")
+	}
+	sess.actions = append(sess.actions, &deleteCompareExchangeAction{key, index})
+	return nil
+}
@@ -0,0 +1,73 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterWideSaveChanges(t *testing.T) {
+	var batch struct {
+		TransactionMode string
+		Commands        []map[string]interface{}
+	}
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Results": [{"Type": "PUT", "@id": "foo-queries/1", "@change-vector": "A:1"}, {"Type": "CompareExchangePUT"}]}`))
+	}))
+
+	ctx, sess := db.NewSession(context.Background(), WithClusterWideTransaction())
+	foo := &FooQueryModel{
+		ID:          "foo-queries/1",
+		DisplayName: "Foo1",
+	}
+	require.NoError(t, db.Collection(new(FooQueryModel)).Put(ctx, foo))
+	require.NoError(t, sess.PutCompareExchange("emails/foo@example.com", "foo-queries/1", 0))
+	require.NoError(t, sess.SaveChanges(ctx))
+
+	require.Equal(t, batch.TransactionMode, "ClusterWide")
+	require.Len(t, batch.Commands, 2)
+	require.Nil(t, batch.Commands[0]["ChangeVector"])
+	require.Equal(t, batch.Commands[1]["Type"], "CompareExchangePUT")
+	require.Equal(t, batch.Commands[1]["Key"], "emails/foo@example.com")
+	require.Equal(t, foo.ChangeVector(), "A:1")
+}
+
+func TestCompareExchangeRequiresClusterWideSession(t *testing.T) {
+	_, sess := new(Database).NewSession(context.Background())
+	require.Error(t, sess.PutCompareExchange("emails/foo@example.com", "foo", 0))
+}
+
+func TestCompareExchange(t *testing.T) {
+	ctx := context.Background()
+	db := initTestbed(t)
+
+	cmpxchg := db.CompareExchange("emails/foo@example.com")
+	var value string
+	if index, err := cmpxchg.Get(ctx, &value); err == nil {
+		require.NoError(t, cmpxchg.Delete(ctx, index))
+	}
+
+	index, err := cmpxchg.Put(ctx, "foo-queries/1", 0)
+	require.NoError(t, err)
+
+	_, err = cmpxchg.Put(ctx, "foo-queries/2", 0)
+	require.True(t, errors.Is(err, ErrConcurrentTransaction))
+
+	current, err := cmpxchg.Get(ctx, &value)
+	require.NoError(t, err)
+	require.Equal(t, current, index)
+	require.Equal(t, value, "foo-queries/1")
+
+	require.NoError(t, cmpxchg.Delete(ctx, index))
+	_, err = cmpxchg.Get(ctx, &value)
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+}
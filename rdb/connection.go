@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/altipla-consulting/errors"
@@ -25,6 +27,14 @@ type connection struct {
 	configmux sync.RWMutex
 	client    *http.Client
 	base      *url.URL
+	tlsConfig *tls.Config
+
+	// Nodes of the database topology when it is replicated in a cluster. The base
+	// URL is the node that receives the requests and it changes if that node fails.
+	nodes              []*url.URL
+	topologyEtag       int64
+	topologyUpdated    time.Time
+	refreshingTopology atomic.Bool
 
 	debug  bool
 	dbname string
@@ -65,11 +75,12 @@ func newSecureConnection(credentials Credentials, dbname string, debug bool) (*c
 		return nil, errors.Trace(err)
 	}
 
+	conn.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+	}
 	conn.client.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			RootCAs:      rootCAs,
-		},
+		TLSClientConfig: conn.tlsConfig,
 	}
 	if conn.debug {
 		conn.client.Transport = &debugTransport{conn.client.Transport}
@@ -109,26 +120,77 @@ func (tr *debugTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 
 func (conn *connection) sendRequest(ctx context.Context, r *http.Request) (*http.Response, error) {
 	conn.configmux.RLock()
-	defer conn.configmux.RUnlock()
+	client := conn.client
+	conn.configmux.RUnlock()
+
+	return conn.send(ctx, client, r)
+}
 
+// sendStreamRequest sends the request without the global timeout of the client
+// because the response body may take a long time to be read completely. The
+// context should be used to cancel it instead.
+func (conn *connection) sendStreamRequest(ctx context.Context, r *http.Request) (*http.Response, error) {
+	conn.configmux.RLock()
+	client := &http.Client{
+		Transport: conn.client.Transport,
+	}
+	conn.configmux.RUnlock()
+
+	return conn.send(ctx, client, r)
+}
+
+// send sends the request to the node it was built for. If the node is not available
+// and the request can be retried safely it fails over to the next nodes of the
+// topology, that will receive the next requests too.
+func (conn *connection) send(ctx context.Context, client *http.Client, r *http.Request) (*http.Response, error) {
 	r = r.WithContext(ctx)
 	r.Header.Set("User-Agent", "ravendb-go-client/4.0.0")
 	r.Header.Set("Raven-Client-Version", "4.0.0")
 
-	resp, err := conn.client.Do(r)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
+	nodes := conn.failoverNodes(r)
+	for i, node := range nodes {
+		if i > 0 {
+			r.URL.Scheme = node.Scheme
+			r.URL.Host = node.Host
+			r.Host = ""
+			if r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				r.Body = body
+			}
+		}
 
-	switch resp.StatusCode {
-	case http.StatusNotFound, http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusConflict:
-		return resp, nil
+		resp, err := client.Do(r)
+		last := i == len(nodes)-1
+		if err != nil {
+			if last || ctx.Err() != nil {
+				return nil, errors.Trace(err)
+			}
+			slog.Warn("RavenDB node failed, trying the next one", slog.String("node", node.Host), slog.String("error", err.Error()))
+			conn.failover(node, nodes[i+1])
+			continue
+		}
+		if resp.StatusCode == http.StatusServiceUnavailable && !last {
+			_ = resp.Body.Close()
+			slog.Warn("RavenDB node unavailable, trying the next one", slog.String("node", node.Host))
+			conn.failover(node, nodes[i+1])
+			continue
+		}
+		conn.refreshTopology(resp)
 
-	default:
-		err := NewUnexpectedStatusError(r, resp)
-		_ = resp.Body.Close()
-		return nil, err
+		switch resp.StatusCode {
+		case http.StatusNotFound, http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusConflict:
+			return resp, nil
+
+		default:
+			err := NewUnexpectedStatusError(r, resp)
+			_ = resp.Body.Close()
+			return nil, err
+		}
 	}
+	panic("should not reach here")
 }
 
 func (conn *connection) buildGET(path string, args map[string]interface{}) (*http.Request, error) {
@@ -293,6 +355,34 @@ func (conn *connection) buildPATCH(path string, args map[string]string, body int
 	return r, nil
 }
 
+// buildStream prepares a request that sends the body as is instead of encoding
+// it as JSON, to upload binary content.
+func (conn *connection) buildStream(method, path string, args map[string]string, contentType string, body io.Reader) (*http.Request, error) {
+	conn.configmux.RLock()
+	defer conn.configmux.RUnlock()
+
+	q := make(url.Values)
+	for k, v := range args {
+		q.Set(k, v)
+	}
+	u := &url.URL{
+		Scheme:   conn.base.Scheme,
+		Host:     conn.base.Host,
+		Path:     path,
+		RawQuery: q.Encode(),
+	}
+
+	r, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	return r, nil
+}
+
 func (conn *connection) endpoint(segment string) string {
 	return "/databases/" + conn.dbname + "/" + segment
 }
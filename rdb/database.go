@@ -170,22 +170,28 @@ func (db *Database) updateCredentials(dbname string) secrets.ChangeHook {
 }
 
 func (db *Database) connect(dbname string, credentials Credentials) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
+	var conn *connection
 	var err error
 	if credentials.Key == "" {
-		db.conn, err = newConnection(credentials.Address, dbname, db.debug)
+		conn, err = newConnection(credentials.Address, dbname, db.debug)
 		if err != nil {
 			return errors.Trace(err)
 		}
 	} else {
-		db.conn, err = newSecureConnection(credentials, dbname, db.debug)
+		conn, err = newSecureConnection(credentials, dbname, db.debug)
 		if err != nil {
 			return errors.Trace(err)
 		}
 	}
 
+	// The topology is only needed for failover, the configured address will be
+	// used until it is read in the background.
+	conn.updateTopologyBackground()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.conn = conn
+
 	return nil
 }
 
@@ -228,13 +234,16 @@ func (db *Database) Create(ctx context.Context, replicationFactor int64) error {
 	return nil
 }
 
-func (db *Database) NewSession(ctx context.Context) (context.Context, *Session) {
+func (db *Database) NewSession(ctx context.Context, opts ...SessionOption) (context.Context, *Session) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	sess := &Session{
 		conn: db.conn,
 	}
+	for _, opt := range opts {
+		opt(sess)
+	}
 	ctx = context.WithValue(ctx, keySession, sess)
 	return ctx, sess
 }
@@ -315,11 +324,19 @@ func (db *Database) CreateIndex(ctx context.Context, name string, index Index) e
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	input, err := buildIndex(name, index)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(db.conn.createIndexes(ctx, []*api.Index{input}))
+}
+
+func buildIndex(name string, index Index) (*api.Index, error) {
 	if name == "" {
-		return errors.Errorf("index name is required to create it")
+		return nil, errors.Errorf("index name is required to create it")
 	}
 	if len(index.Maps) == 0 {
-		return errors.Errorf("at least one map is required for a custom index")
+		return nil, errors.Errorf("at least one map is required for a custom index")
 	}
 
 	input := &api.Index{
@@ -342,12 +359,12 @@ func (db *Database) CreateIndex(ctx context.Context, name string, index Index) e
 	for _, source := range index.AdditionalSources {
 		content, err := ioutil.ReadFile(source)
 		if err != nil {
-			return errors.Trace(err)
+			return nil, errors.Trace(err)
 		}
 		input.AdditionalSources[filepath.Base(source)] = string(content)
 	}
 
-	return errors.Trace(db.conn.createIndexes(ctx, []*api.Index{input}))
+	return input, nil
 }
 
 func (db *Database) Patch(ctx context.Context, query *RQLQuery) (*Operation, error) {
@@ -2,6 +2,8 @@ package rdb
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -16,6 +18,17 @@ func initTestbed(t *testing.T) *Database {
 	return db
 }
 
+// newFakeDatabase opens a database against a fake server that answers every
+// request with the handler. The server is closed when the test finishes.
+func newFakeDatabase(t *testing.T, handler http.Handler) *Database {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conn, err := newConnection(server.URL, "foo-db", false)
+	require.NoError(t, err)
+	return &Database{conn: conn}
+}
+
 type WrongModel struct {
 	ModelTracking
 
@@ -302,3 +315,21 @@ func TestDatabaseConfigureDefaultRevisions(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, desc.Revisions.Default)
 }
+
+func TestDeployIndexesDrift(t *testing.T) {
+	ctx := context.Background()
+	db := initTestbed(t)
+
+	indexes := map[string]Index{
+		"DeployIndex": {
+			Maps: []string{`from foo in docs.Foo select new { foo.DisplayName }`},
+		},
+	}
+	_, err := db.DeployIndexes(ctx, indexes)
+	require.NoError(t, err)
+
+	deployment, err := db.DeployIndexes(ctx, indexes)
+	require.NoError(t, err)
+	require.False(t, deployment.HasDrift())
+	require.Equal(t, deployment.Unchanged, []string{"DeployIndex"})
+}
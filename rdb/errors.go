@@ -14,6 +14,10 @@ var (
 	ErrNoSuchEntity          = errors.New("rdb: no such entity")
 	ErrConcurrentTransaction = errors.New("rdb: concurrent transaction")
 	ErrDatabaseDoesNotExists = errors.New("rdb: database does not exists")
+
+	// ErrDone is returned from the Next() method of an iterator when all results
+	// have been read.
+	ErrDone = errors.New("rdb: done")
 )
 
 type noSuchEntityError struct {
@@ -0,0 +1,311 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Facet is an aggregation of the results of a query that can be requested with
+// AggregateBy.
+type Facet interface {
+	// Name of the facet in the results.
+	Name() string
+
+	// RQL returns the facet expression that will be selected.
+	RQL(params *Params) string
+}
+
+type FacetOrder string
+
+const (
+	FacetOrderValueAsc  = FacetOrder("ValueAsc")
+	FacetOrderValueDesc = FacetOrder("ValueDesc")
+	FacetOrderCountAsc  = FacetOrder("CountAsc")
+	FacetOrderCountDesc = FacetOrder("CountDesc")
+)
+
+type facetOptions struct {
+	alias        string
+	options      *api.FacetOptions
+	aggregations []string
+}
+
+type FacetOption func(opts *facetOptions)
+
+// FacetAlias changes the name of the facet in the results.
+func FacetAlias(alias string) FacetOption {
+	return func(opts *facetOptions) {
+		opts.alias = alias
+	}
+}
+
+// FacetPage returns only a page of terms of a field facet. The rest of them can be
+// counted with FacetIncludeRemainingTerms.
+func FacetPage(start, pageSize int64) FacetOption {
+	return func(opts *facetOptions) {
+		opts.options.Start = start
+		opts.options.PageSize = pageSize
+	}
+}
+
+// FacetSort changes the order of the terms of a field facet.
+func FacetSort(order FacetOrder) FacetOption {
+	return func(opts *facetOptions) {
+		opts.options.TermSortMode = string(order)
+	}
+}
+
+// FacetIncludeRemainingTerms returns the terms outside the requested page of a
+// field facet.
+func FacetIncludeRemainingTerms() FacetOption {
+	return func(opts *facetOptions) {
+		opts.options.IncludeRemainingTerms = true
+	}
+}
+
+// FacetSum adds the values of the numeric field in each bucket of the facet.
+func FacetSum(field string) FacetOption {
+	return facetAggregation("sum", field)
+}
+
+// FacetAverage averages the values of the numeric field in each bucket of the facet.
+func FacetAverage(field string) FacetOption {
+	return facetAggregation("avg", field)
+}
+
+// FacetMin returns the minimum value of the numeric field in each bucket of the facet.
+func FacetMin(field string) FacetOption {
+	return facetAggregation("min", field)
+}
+
+// FacetMax returns the maximum value of the numeric field in each bucket of the facet.
+func FacetMax(field string) FacetOption {
+	return facetAggregation("max", field)
+}
+
+func facetAggregation(fn, field string) FacetOption {
+	return func(opts *facetOptions) {
+		opts.aggregations = append(opts.aggregations, fmt.Sprintf("%s(%s)", fn, field))
+	}
+}
+
+func applyFacetOptions(opts []FacetOption) *facetOptions {
+	applied := &facetOptions{
+		options: new(api.FacetOptions),
+	}
+	for _, opt := range opts {
+		opt(applied)
+	}
+	return applied
+}
+
+type fieldFacet struct {
+	field string
+	opts  *facetOptions
+}
+
+// FacetField counts the results for each distinct term of the field.
+func FacetField(field string, opts ...FacetOption) Facet {
+	return &fieldFacet{field, applyFacetOptions(opts)}
+}
+
+func (facet *fieldFacet) Name() string {
+	if facet.opts.alias != "" {
+		return facet.opts.alias
+	}
+	return facet.field
+}
+
+func (facet *fieldFacet) RQL(params *Params) string {
+	args := append([]string{facet.field}, facet.opts.aggregations...)
+	if *facet.opts.options != (api.FacetOptions{}) {
+		args = append(args, params.Next(facet.opts.options))
+	}
+	return fmt.Sprintf("facet(%s) as %s", strings.Join(args, ", "), facet.Name())
+}
+
+// FacetRange is a bucket of values of a range facet. A nil From or To leaves
+// that side of the range open. From is inclusive and To exclusive.
+type FacetRange struct {
+	Label    string
+	From, To interface{}
+}
+
+type rangeFacet struct {
+	name   string
+	field  string
+	ranges []FacetRange
+	opts   *facetOptions
+}
+
+// FacetRanges counts the results whose field is inside each range. The name is
+// required to identify the facet in the results. Only the aggregation options
+// apply to range facets.
+func FacetRanges(name, field string, ranges []FacetRange, opts ...FacetOption) Facet {
+	if name == "" {
+		panic("range facets should have a name")
+	}
+	if len(ranges) == 0 {
+		panic("range facets should have at least one range")
+	}
+	return &rangeFacet{name, field, ranges, applyFacetOptions(opts)}
+}
+
+func (facet *rangeFacet) Name() string {
+	return facet.name
+}
+
+func (facet *rangeFacet) RQL(params *Params) string {
+	var args []string
+	for _, r := range facet.ranges {
+		switch {
+		case r.From != nil && r.To != nil:
+			args = append(args, fmt.Sprintf("%s >= %s and %s < %s", facet.field, params.Next(r.From), facet.field, params.Next(r.To)))
+		case r.From != nil:
+			args = append(args, fmt.Sprintf("%s >= %s", facet.field, params.Next(r.From)))
+		case r.To != nil:
+			args = append(args, fmt.Sprintf("%s < %s", facet.field, params.Next(r.To)))
+		default:
+			panic("ranges should have at least one limit")
+		}
+	}
+	args = append(args, facet.opts.aggregations...)
+	return fmt.Sprintf("facet(%s) as %s", strings.Join(args, ", "), facet.name)
+}
+
+// FacetResult contains the buckets of a facet.
+type FacetResult struct {
+	Name   string
+	Values []*FacetValue
+
+	// Only filled when requested with FacetIncludeRemainingTerms.
+	RemainingTerms      []string
+	RemainingTermsCount int64
+	RemainingHits       int64
+}
+
+// FacetValue is a bucket of a facet: a term of a field facet or a range of a range
+// facet. The aggregations are only filled if they were requested.
+type FacetValue struct {
+	// Term of the field, or label of the range.
+	Range string
+	Count int64
+
+	// Field of the aggregations when multiple of them are requested. The server
+	// returns a different value for each field and bucket.
+	Field string
+
+	Sum, Average, Min, Max *float64
+}
+
+// AggregationRQL returns the RQL and params of the aggregation of the query in
+// the facets.
+func (q *Query) AggregationRQL(facets ...Facet) (string, map[string]interface{}) {
+	q = q.Clone()
+	for _, fn := range q.enforcers {
+		q = fn(q)
+	}
+
+	params := NewParams()
+	parts := q.sourceRQL(params)
+	selects := make([]string, len(facets))
+	for i, facet := range facets {
+		selects[i] = facet.RQL(params)
+	}
+	parts = append(parts, "select "+strings.Join(selects, ", "))
+
+	return strings.Join(parts, " "), params.values
+}
+
+// AggregateBy counts the results of the query in the facets, applying the same
+// filters. Pagination, orders and projections of the query are ignored. It
+// returns the results of each facet by name.
+func (q *Query) AggregateBy(ctx context.Context, facets ...Facet) (map[string]*FacetResult, error) {
+	if len(facets) == 0 {
+		return nil, errors.Errorf("at least one facet is required to aggregate a query")
+	}
+
+	rql, params := q.AggregationRQL(facets...)
+	query := &api.Query{
+		Query:                  rql,
+		QueryParameters:        params,
+		WaitForNonStaleResults: q.strongConsistency || q.db.strongConsistency,
+	}
+	if query.WaitForNonStaleResults {
+		query.WaitForNonStaleResultsTimeout = "00:00:15"
+	}
+	r, err := q.conn.buildPOST(q.conn.endpoint("queries"), nil, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := q.conn.sendRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		results := new(api.FacetResults)
+		if err := json.NewDecoder(resp.Body).Decode(results); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return buildFacetResults(facets, results), nil
+	case http.StatusNotFound:
+		return nil, errors.Errorf("index not found: %s", q.index)
+	default:
+		return nil, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+func buildFacetResults(facets []Facet, results *api.FacetResults) map[string]*FacetResult {
+	byName := make(map[string]Facet)
+	for _, facet := range facets {
+		byName[facet.Name()] = facet
+	}
+
+	aggregated := make(map[string]*FacetResult)
+	for _, result := range results.Results {
+		facet := &FacetResult{
+			Name:                result.Name,
+			RemainingTerms:      result.RemainingTerms,
+			RemainingTermsCount: result.RemainingTermsCount,
+			RemainingHits:       result.RemainingHits,
+		}
+		// The server returns the ranges in the same order they were requested, use
+		// it to assign their labels.
+		rf, _ := byName[result.Name].(*rangeFacet)
+		labels := make(map[string]string)
+		for _, value := range result.Values {
+			v := &FacetValue{
+				Range:   value.Range,
+				Count:   value.Count,
+				Field:   value.Name,
+				Sum:     value.Sum,
+				Average: value.Average,
+				Min:     value.Min,
+				Max:     value.Max,
+			}
+			if rf != nil {
+				if _, ok := labels[value.Range]; !ok && len(labels) < len(rf.ranges) {
+					labels[value.Range] = rf.ranges[len(labels)].Label
+				}
+				if label := labels[value.Range]; label != "" {
+					v.Range = label
+				}
+			}
+
+			facet.Values = append(facet.Values, v)
+		}
+		aggregated[result.Name] = facet
+	}
+
+	return aggregated
+}
@@ -0,0 +1,72 @@
+package rdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+func TestAggregationRQL(t *testing.T) {
+	db := &Database{}
+	collection := db.Collection(new(FooQueryNumericModel))
+
+	q := collection.Filter("Number >", 1).OrderBy("Number").Limit(10)
+	rql, params := q.AggregationRQL(
+		FacetField("Number", FacetSort(FacetOrderCountDesc), FacetSum("Number")),
+		FacetRanges("Ranges", "Number", []FacetRange{
+			{Label: "low", To: 3},
+			{Label: "high", From: 3},
+		}),
+	)
+	require.Equal(t, rql, `from FooQueryNumericModels where Number > $p0 select facet(Number, sum(Number), $p1) as Number, facet(Number < $p2, Number >= $p3) as Ranges`)
+	require.Equal(t, params, map[string]interface{}{
+		"p0": 1,
+		"p1": &api.FacetOptions{TermSortMode: "CountDesc"},
+		"p2": 3,
+		"p3": 3,
+	})
+}
+
+func TestBuildFacetResultsRangeLabels(t *testing.T) {
+	facets := []Facet{
+		FacetRanges("Ranges", "Number", []FacetRange{
+			{Label: "low", To: 3},
+			{Label: "high", From: 3},
+		}),
+	}
+	results := &api.FacetResults{
+		Results: []*api.FacetResult{
+			{
+				Name: "Ranges",
+				Values: []*api.FacetValue{
+					{Range: "Number < 3", Count: 2},
+					{Range: "Number >= 3", Count: 1},
+				},
+			},
+		},
+	}
+
+	aggregated := buildFacetResults(facets, results)
+	require.Len(t, aggregated["Ranges"].Values, 2)
+	require.Equal(t, aggregated["Ranges"].Values[0].Range, "low")
+	require.EqualValues(t, aggregated["Ranges"].Values[0].Count, 2)
+	require.Equal(t, aggregated["Ranges"].Values[1].Range, "high")
+	require.EqualValues(t, aggregated["Ranges"].Values[1].Count, 1)
+}
+
+func TestAggregateBy(t *testing.T) {
+	ctx := context.Background()
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+
+	aggregated, err := collection.Filter("DisplayName !=", "Foo1").AggregateBy(ctx, FacetField("Alternative"))
+	require.NoError(t, err)
+
+	require.Len(t, aggregated["Alternative"].Values, 2)
+	for _, value := range aggregated["Alternative"].Values {
+		require.EqualValues(t, value.Count, 1)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"slices"
 	"strings"
 	"time"
+
+	"libs.altipla.consulting/geo"
 )
 
 type Params struct {
@@ -278,3 +280,40 @@ func FilterBetween(field string, start, end interface{}) QueryFilter {
 func (f *betweenFilter) RQL(params *Params) string {
 	return fmt.Sprintf("%s between %s and %s", f.field, params.Next(f.start), params.Next(f.end))
 }
+
+// spatialPoint returns the RQL of the point stored in a geo.Point field of the model.
+func spatialPoint(field string) string {
+	return fmt.Sprintf("spatial.point(%s.Lat, %s.Lng)", field, field)
+}
+
+type withinRadiusFilter struct {
+	field  string
+	center geo.Point
+	km     float64
+}
+
+// FilterWithinRadius returns the results whose geo.Point field is inside the circle
+// with the center and radius in kilometers.
+func FilterWithinRadius(field string, center geo.Point, km float64) QueryFilter {
+	return &withinRadiusFilter{field, center, km}
+}
+
+func (f *withinRadiusFilter) RQL(params *Params) string {
+	circle := fmt.Sprintf("spatial.circle(%s, %s, %s, 'Kilometers')", params.Next(f.km), params.Next(f.center.Lat), params.Next(f.center.Lng))
+	return fmt.Sprintf("spatial.within(%s, %s)", spatialPoint(f.field), circle)
+}
+
+type withinWKTFilter struct {
+	field string
+	shape string
+}
+
+// FilterWithinWKT returns the results whose geo.Point field is inside the shape
+// in WKT (Well Known Text) format, e.g. "POLYGON((...))".
+func FilterWithinWKT(field, shape string) QueryFilter {
+	return &withinWKTFilter{field, shape}
+}
+
+func (f *withinWKTFilter) RQL(params *Params) string {
+	return fmt.Sprintf("spatial.within(%s, spatial.wkt(%s))", spatialPoint(f.field), params.Next(f.shape))
+}
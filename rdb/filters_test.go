@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/geo"
 )
 
 func TestNestedOr(t *testing.T) {
@@ -26,3 +28,27 @@ func TestParamsTime(t *testing.T) {
 	require.Equal(t, p.Next(time.Date(2019, time.January, 2, 3, 4, 5, 0, time.UTC)), "$p0")
 	require.Equal(t, p.values["p0"], "2019-01-02T03:04:05.0000000Z")
 }
+
+func TestFilterWithinRadius(t *testing.T) {
+	q := FilterWithinRadius("Location", geo.Point{Lng: -3.7, Lat: 40.4}, 10)
+	params := NewParams()
+	rql := q.RQL(params)
+
+	require.Equal(t, rql, `spatial.within(spatial.point(Location.Lat, Location.Lng), spatial.circle($p0, $p1, $p2, 'Kilometers'))`)
+	require.Equal(t, params.values, map[string]interface{}{
+		"p0": 10.0,
+		"p1": 40.4,
+		"p2": -3.7,
+	})
+}
+
+func TestFilterWithinWKT(t *testing.T) {
+	q := FilterWithinWKT("Location", "POLYGON((-4 40, -3 40, -3 41, -4 41, -4 40))")
+	params := NewParams()
+	rql := q.RQL(params)
+
+	require.Equal(t, rql, `spatial.within(spatial.point(Location.Lat, Location.Lng), spatial.wkt($p0))`)
+	require.Equal(t, params.values, map[string]interface{}{
+		"p0": "POLYGON((-4 40, -3 40, -3 41, -4 41, -4 40))",
+	})
+}
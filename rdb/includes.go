@@ -1,5 +1,9 @@
 package rdb
 
+import (
+	"time"
+)
+
 type IncludeOption func(cnf *includesConfig)
 
 func Include(includes ...string) IncludeOption {
@@ -14,9 +18,25 @@ func IncludeAllCounters() IncludeOption {
 	}
 }
 
+// IncludeTimeSeries reads the entries of the time series between from and to
+// when loading documents with Get or GetMulti. Zero times leave the range open
+// at that side. They can be read later with Session.TimeSeries without
+// contacting the server.
+func IncludeTimeSeries(name string, from, to time.Time) IncludeOption {
+	return func(cnf *includesConfig) {
+		cnf.timeSeries = append(cnf.timeSeries, timeSeriesInclude{name, from, to})
+	}
+}
+
 type includesConfig struct {
 	includes    []string
 	allCounters bool
+	timeSeries  []timeSeriesInclude
+}
+
+type timeSeriesInclude struct {
+	name     string
+	from, to time.Time
 }
 
 func applyModelIncludes(opts ...IncludeOption) []string {
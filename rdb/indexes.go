@@ -0,0 +1,257 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// IndexDeployment reports the changes applied by DeployIndexes, or that would be
+// applied when using WithDeployDryRun.
+type IndexDeployment struct {
+	Created   []string
+	Updated   []string
+	Deleted   []string
+	Unchanged []string
+}
+
+// HasDrift returns true if the indexes of the server were different from the
+// deployed ones.
+func (deployment *IndexDeployment) HasDrift() bool {
+	return len(deployment.Created) > 0 || len(deployment.Updated) > 0 || len(deployment.Deleted) > 0
+}
+
+type deployOptions struct {
+	deleteUnknown bool
+	dryRun        bool
+}
+
+type DeployOption func(opts *deployOptions)
+
+// WithDeleteUnknownIndexes removes the indexes of the server that are not deployed.
+// Automatic indexes created by the server for dynamic queries are always kept.
+func WithDeleteUnknownIndexes() DeployOption {
+	return func(opts *deployOptions) {
+		opts.deleteUnknown = true
+	}
+}
+
+// WithDeployDryRun compares the indexes with the server but does not change them.
+func WithDeployDryRun() DeployOption {
+	return func(opts *deployOptions) {
+		opts.dryRun = true
+	}
+}
+
+// DeployIndexes compares the definitions of the indexes with the ones in the
+// server and creates or updates only the changed ones. Then it waits until the
+// changed indexes are not stale anymore, so queries won't read outdated results
+// after a deployment. Use a context with a deadline to limit the wait.
+func (db *Database) DeployIndexes(ctx context.Context, indexes map[string]Index, opts ...DeployOption) (*IndexDeployment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	deployOpts := new(deployOptions)
+	for _, opt := range opts {
+		opt(deployOpts)
+	}
+
+	desc, err := db.conn.descriptor(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	deployment := new(IndexDeployment)
+	var changed []*api.Index
+	for _, name := range names {
+		input, err := buildIndex(name, indexes[name])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		current, ok := desc.Indexes[name]
+		switch {
+		case !ok:
+			deployment.Created = append(deployment.Created, name)
+		case !sameIndex(current, input):
+			deployment.Updated = append(deployment.Updated, name)
+		default:
+			deployment.Unchanged = append(deployment.Unchanged, name)
+			continue
+		}
+		changed = append(changed, input)
+	}
+	if deployOpts.deleteUnknown {
+		for name := range desc.Indexes {
+			if _, ok := indexes[name]; !ok && !strings.HasPrefix(name, "Auto/") {
+				deployment.Deleted = append(deployment.Deleted, name)
+			}
+		}
+		sort.Strings(deployment.Deleted)
+	}
+
+	if deployOpts.dryRun {
+		return deployment, nil
+	}
+
+	if err := db.conn.createIndexes(ctx, changed); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, name := range deployment.Deleted {
+		if err := db.conn.deleteIndex(ctx, name); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	for _, index := range changed {
+		if err := db.conn.waitForIndex(ctx, index.Name); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return deployment, nil
+}
+
+// sameIndex compares the definitions ignoring the differences introduced by the
+// server when storing them.
+func sameIndex(current, input *api.Index) bool {
+	if current.Type != input.Type {
+		return false
+	}
+
+	if len(current.Maps) != len(input.Maps) {
+		return false
+	}
+	currentMaps := normalizeIndexMaps(current.Maps)
+	inputMaps := normalizeIndexMaps(input.Maps)
+	for i := range currentMaps {
+		if currentMaps[i] != inputMaps[i] {
+			return false
+		}
+	}
+
+	var currentReduce, inputReduce string
+	if current.Reduce != nil {
+		currentReduce = strings.TrimSpace(*current.Reduce)
+	}
+	if input.Reduce != nil {
+		inputReduce = strings.TrimSpace(*input.Reduce)
+	}
+	if currentReduce != inputReduce {
+		return false
+	}
+
+	if !reflect.DeepEqual(normalizeIndexFields(current.Fields), normalizeIndexFields(input.Fields)) {
+		return false
+	}
+
+	if len(current.AdditionalSources) == 0 && len(input.AdditionalSources) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(current.AdditionalSources, input.AdditionalSources)
+}
+
+func normalizeIndexMaps(maps []string) []string {
+	normalized := make([]string, len(maps))
+	for i, m := range maps {
+		normalized[i] = strings.TrimSpace(m)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func normalizeIndexFields(fields map[string]*api.IndexFieldOptions) map[string]api.IndexFieldOptions {
+	normalized := make(map[string]api.IndexFieldOptions)
+	for name, field := range fields {
+		if field == nil {
+			continue
+		}
+		opts := *field
+		if opts.Indexing == "Default" {
+			opts.Indexing = ""
+		}
+		if opts.Storage == "No" {
+			opts.Storage = ""
+		}
+		if opts != (api.IndexFieldOptions{}) {
+			normalized[name] = opts
+		}
+	}
+	return normalized
+}
+
+func (conn *connection) deleteIndex(ctx context.Context, name string) error {
+	r, err := conn.buildDELETE(conn.endpoint("indexes"), map[string]string{"name": name})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return NewUnexpectedStatusError(r, resp)
+	}
+	return nil
+}
+
+// waitForIndex waits until the index is not stale, checking it periodically.
+func (conn *connection) waitForIndex(ctx context.Context, name string) error {
+	backoff := 100 * time.Millisecond
+	for {
+		r, err := conn.buildGET(conn.endpoint("indexes/stats"), map[string]interface{}{"name": name})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		resp, err := conn.sendRequest(ctx, r)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := NewUnexpectedStatusError(r, resp)
+			_ = resp.Body.Close()
+			return err
+		}
+		stats := new(api.IndexStatsResults)
+		if err := json.NewDecoder(resp.Body).Decode(stats); err != nil {
+			_ = resp.Body.Close()
+			return errors.Trace(err)
+		}
+		_ = resp.Body.Close()
+
+		if len(stats.Results) == 0 {
+			return errors.Errorf("index not found: %s", name)
+		}
+		if stats.Results[0].State == "Error" {
+			return errors.Errorf("index %s is in error state", name)
+		}
+		if !stats.Results[0].IsStale {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-time.After(backoff):
+		}
+		if backoff < 2*time.Second {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+func TestSameIndex(t *testing.T) {
+	input, err := buildIndex("FooIndex", Index{
+		Maps:     []string{`from foo in docs.Foo select new { foo.DisplayName }`},
+		Indexing: map[string]FieldIndexing{"DisplayName": FieldIndexingSearch},
+	})
+	require.NoError(t, err)
+
+	current := &api.Index{
+		Type: "Map",
+		Name: "FooIndex",
+		Maps: []string{"\n  from foo in docs.Foo select new { foo.DisplayName }\n"},
+		Fields: map[string]*api.IndexFieldOptions{
+			"DisplayName": {Indexing: "Search", Storage: "No"},
+			"Other":       {Indexing: "Default"},
+		},
+	}
+	require.True(t, sameIndex(current, input))
+
+	current.Fields["DisplayName"].Indexing = "Exact"
+	require.False(t, sameIndex(current, input))
+
+	current.Fields["DisplayName"].Indexing = "Search"
+	current.Maps[0] = `from foo in docs.Foo select new { foo.Name }`
+	require.False(t, sameIndex(current, input))
+}
+
+func TestDeployIndexesChanges(t *testing.T) {
+	var mu sync.Mutex
+	var created []*api.Index
+	var deleted []string
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/admin/databases":
+			fmt.Fprint(w, `{
+				"DatabaseName": "foo-db",
+				"Indexes": {
+					"Unchanged": {"Type": "Map", "Name": "Unchanged", "Maps": ["from foo in docs.Foo select new { foo.A }"]},
+					"Changed": {"Type": "Map", "Name": "Changed", "Maps": ["from foo in docs.Foo select new { foo.A }"]},
+					"Unknown": {"Type": "Map", "Name": "Unknown", "Maps": ["from foo in docs.Foo select new { foo.A }"]},
+					"Auto/Foos/ByA": {"Type": "AutoMap", "Name": "Auto/Foos/ByA"}
+				}
+			}`)
+		case "/databases/foo-db/admin/indexes":
+			req := new(api.IndexesRequest)
+			_ = json.NewDecoder(r.Body).Decode(req)
+			created = append(created, req.Indexes...)
+			w.WriteHeader(http.StatusCreated)
+		case "/databases/foo-db/indexes":
+			deleted = append(deleted, r.URL.Query().Get("name"))
+			w.WriteHeader(http.StatusNoContent)
+		case "/databases/foo-db/indexes/stats":
+			fmt.Fprintf(w, `{"Results": [{"Name": %q, "IsStale": false, "State": "Normal"}]}`, r.URL.Query().Get("name"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	indexes := map[string]Index{
+		"Unchanged": {Maps: []string{`from foo in docs.Foo select new { foo.A }`}},
+		"Changed":   {Maps: []string{`from foo in docs.Foo select new { foo.B }`}},
+		"New":       {Maps: []string{`from foo in docs.Foo select new { foo.C }`}},
+	}
+
+	deployment, err := db.DeployIndexes(context.Background(), indexes, WithDeleteUnknownIndexes(), WithDeployDryRun())
+	require.NoError(t, err)
+	require.True(t, deployment.HasDrift())
+	require.Empty(t, created)
+	require.Empty(t, deleted)
+
+	deployment, err = db.DeployIndexes(context.Background(), indexes, WithDeleteUnknownIndexes())
+	require.NoError(t, err)
+	require.Equal(t, deployment, &IndexDeployment{
+		Created:   []string{"New"},
+		Updated:   []string{"Changed"},
+		Deleted:   []string{"Unknown"},
+		Unchanged: []string{"Unchanged"},
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, created, 2)
+	require.Equal(t, created[0].Name, "Changed")
+	require.Equal(t, created[1].Name, "New")
+	require.Equal(t, deleted, []string{"Unknown"})
+}
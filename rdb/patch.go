@@ -0,0 +1,154 @@
+package rdb
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+var patchFieldRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// DocumentPatch modifies fields of a single document in the server without
+// reading it first. Fields can be nested using dots, e.g. "Address.City".
+type DocumentPatch struct {
+	ctx          context.Context
+	collection   *Collection
+	id           string
+	changeVector string
+	params       *Params
+	operations   []string
+	err          error
+}
+
+// Patch prepares a patch of the document. Add operations to it and call Apply to
+// send them. If there is a session in the context the patch will be sent in the
+// next SaveChanges call.
+func (collection *Collection) Patch(ctx context.Context, id string) *DocumentPatch {
+	return &DocumentPatch{
+		ctx:        ctx,
+		collection: collection,
+		id:         id,
+		params:     NewParams(),
+	}
+}
+
+// IfChangeVector only applies the patch if the document has not changed since it
+// was read with that change vector. Otherwise it returns ErrConcurrentTransaction.
+func (patch *DocumentPatch) IfChangeVector(changeVector string) *DocumentPatch {
+	patch.changeVector = changeVector
+	return patch
+}
+
+func (patch *DocumentPatch) field(field string) string {
+	if !patchFieldRe.MatchString(field) {
+		if patch.err == nil {
+			patch.err = errors.Errorf("invalid patch field: %q", field)
+		}
+	}
+	return "this." + field
+}
+
+// Set replaces the value of the field.
+func (patch *DocumentPatch) Set(field string, value interface{}) *DocumentPatch {
+	patch.operations = append(patch.operations, patch.field(field)+" = "+patch.params.Next(value)+";")
+	return patch
+}
+
+// Increment adds delta to the numeric field. Missing fields start from zero.
+func (patch *DocumentPatch) Increment(field string, delta int64) *DocumentPatch {
+	f := patch.field(field)
+	patch.operations = append(patch.operations, f+" = ("+f+" || 0) + "+patch.params.Next(delta)+";")
+	return patch
+}
+
+// Unset removes the field from the document.
+func (patch *DocumentPatch) Unset(field string) *DocumentPatch {
+	patch.operations = append(patch.operations, "delete "+patch.field(field)+";")
+	return patch
+}
+
+// AddToArray appends the values at the end of the array field. Missing fields
+// start as an empty array.
+func (patch *DocumentPatch) AddToArray(field string, values ...interface{}) *DocumentPatch {
+	f := patch.field(field)
+	patch.operations = append(patch.operations, f+" = ("+f+" || []).concat("+patch.params.Next(values)+");")
+	return patch
+}
+
+// RemoveFromArray removes all the items of the array field equal to the value.
+// Only scalar values like strings or numbers can be compared.
+func (patch *DocumentPatch) RemoveFromArray(field string, value interface{}) *DocumentPatch {
+	f := patch.field(field)
+	patch.operations = append(patch.operations, f+" = ("+f+" || []).filter(item => item !== "+patch.params.Next(value)+");")
+	return patch
+}
+
+// Script returns the JavaScript patch that will be sent to the server and its
+// parameters.
+func (patch *DocumentPatch) Script() (string, map[string]interface{}) {
+	return strings.Join(patch.operations, "\n"), patch.params.values
+}
+
+// Apply sends the patch to the server. If the document does not exist it returns
+// ErrNoSuchEntity. Inside a session the patch is only queued and missing documents
+// are ignored when saving the changes.
+func (patch *DocumentPatch) Apply() error {
+	if len(patch.collection.enforcers) > 0 {
+		return errors.Errorf("cannot enforce entities while calling Patch")
+	}
+	if patch.id == "" {
+		return newNoSuchEntityError("empty id")
+	}
+	if patch.err != nil {
+		return errors.Trace(patch.err)
+	}
+	if len(patch.operations) == 0 {
+		return nil
+	}
+
+	script, values := patch.Script()
+	action := &patchAction{
+		id:           patch.id,
+		changeVector: patch.changeVector,
+		script: &api.PatchScript{
+			Script: script,
+			Values: values,
+		},
+	}
+
+	sess := SessionFromContext(patch.ctx)
+	if sess == nil {
+		ctx, sess := patch.collection.db.NewSession(patch.ctx)
+		sess.actions = append(sess.actions, action)
+		return errors.Trace(sess.SaveChanges(ctx))
+	}
+
+	action.ignoreMissing = true
+	sess.actions = append(sess.actions, action)
+	return nil
+}
+
+type patchAction struct {
+	id           string
+	changeVector string
+	script       *api.PatchScript
+
+	// Missing documents are not an error, like when the patch is sent in a batch.
+	ignoreMissing bool
+}
+
+func (action *patchAction) batchCommand() (api.BatchCommand, error) {
+	cmd := &api.PatchCommand{
+		ID:    action.id,
+		Patch: action.script,
+		Type:  "PATCH",
+	}
+	if action.changeVector != "" {
+		cmd.ChangeVector = &action.changeVector
+	}
+	return cmd, nil
+}
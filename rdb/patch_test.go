@@ -0,0 +1,149 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentPatchScript(t *testing.T) {
+	db := &Database{}
+	patch := db.Collection(new(PatchModel)).Patch(context.Background(), "patch-models/1").
+		Set("Value", "foo").
+		Increment("Stats.Views", 2).
+		Unset("Old").
+		AddToArray("Array", "bar", "baz").
+		RemoveFromArray("Array", "qux")
+
+	script, values := patch.Script()
+	require.Equal(t, script, `this.Value = $p0;
+this.Stats.Views = (this.Stats.Views || 0) + $p1;
+delete this.Old;
+this.Array = (this.Array || []).concat($p2);
+this.Array = (this.Array || []).filter(item => item !== $p3);`)
+	require.Equal(t, values, map[string]interface{}{
+		"p0": "foo",
+		"p1": int64(2),
+		"p2": []interface{}{"bar", "baz"},
+		"p3": "qux",
+	})
+}
+
+func TestDocumentPatchInvalidField(t *testing.T) {
+	db := &Database{}
+	err := db.Collection(new(PatchModel)).Patch(context.Background(), "patch-models/1").Set("Value; this.Other", "foo").Apply()
+	require.EqualError(t, err, `invalid patch field: "Value; this.Other"`)
+}
+
+func TestDocumentPatchApply(t *testing.T) {
+	var method, id, ifMatch string
+	var body map[string]interface{}
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		id = r.URL.Query().Get("id")
+		ifMatch = r.Header.Get("If-Match")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch id {
+		case "patch-models/1":
+			_, _ = w.Write([]byte(`{"Status": "Patched"}`))
+		case "patch-models/2":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	collection := db.Collection(new(PatchModel))
+
+	ctx := context.Background()
+	require.NoError(t, collection.Patch(ctx, "patch-models/1").IfChangeVector("A:1").Set("Value", "foo").Apply())
+	require.Equal(t, method, http.MethodPatch)
+	require.Equal(t, id, "patch-models/1")
+	require.Equal(t, ifMatch, "A:1")
+	require.Equal(t, body, map[string]interface{}{
+		"Patch": map[string]interface{}{
+			"Script": "this.Value = $p0;",
+			"Values": map[string]interface{}{"p0": "foo"},
+		},
+	})
+
+	err := collection.Patch(ctx, "patch-models/2").IfChangeVector("A:1").Set("Value", "foo").Apply()
+	require.True(t, errors.Is(err, ErrConcurrentTransaction))
+
+	err = collection.Patch(ctx, "patch-models/3").Set("Value", "foo").Apply()
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+
+	ctx, sess := db.NewSession(ctx)
+	require.NoError(t, collection.Patch(ctx, "patch-models/3").Set("Value", "foo").Apply())
+	require.NoError(t, sess.SaveChanges(ctx))
+	require.Equal(t, id, "patch-models/3")
+}
+
+func TestDocumentPatchSession(t *testing.T) {
+	var batch map[string]interface{}
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Results": [{}, {}]}`))
+	}))
+	collection := db.Collection(new(PatchModel))
+
+	ctx, sess := db.NewSession(context.Background())
+	require.NoError(t, collection.Patch(ctx, "patch-models/1").Increment("Views", 1).Apply())
+	require.NoError(t, collection.Patch(ctx, "patch-models/2").IfChangeVector("A:2").Unset("Value").Apply())
+	require.Nil(t, batch)
+	require.NoError(t, sess.SaveChanges(ctx))
+
+	require.Equal(t, batch["Commands"], []interface{}{
+		map[string]interface{}{
+			"Id":   "patch-models/1",
+			"Type": "PATCH",
+			"Patch": map[string]interface{}{
+				"Script": "this.Views = (this.Views || 0) + $p0;",
+				"Values": map[string]interface{}{"p0": float64(1)},
+			},
+		},
+		map[string]interface{}{
+			"Id":           "patch-models/2",
+			"ChangeVector": "A:2",
+			"Type":         "PATCH",
+			"Patch": map[string]interface{}{
+				"Script": "delete this.Value;",
+				"Values": map[string]interface{}{},
+			},
+		},
+	})
+}
+
+func TestCollectionPatch(t *testing.T) {
+	ctx := context.Background()
+	db := initTestbed(t)
+	collection := db.Collection(new(PatchModel))
+
+	require.NoError(t, collection.DeleteEverything(ctx))
+
+	foo := &PatchModel{
+		ID:    "patch-models/1",
+		Value: "foo",
+		Array: []string{"foo", "bar"},
+	}
+	require.NoError(t, collection.Put(ctx, foo))
+
+	patch := collection.Patch(ctx, foo.ID).
+		IfChangeVector(foo.ChangeVector()).
+		Set("Value", "baz").
+		AddToArray("Array", "baz").
+		RemoveFromArray("Array", "foo")
+	require.NoError(t, patch.Apply())
+
+	var other *PatchModel
+	require.NoError(t, collection.Get(ctx, foo.ID, &other))
+	require.Equal(t, other.Value, "baz")
+	require.Equal(t, other.Array, []string{"bar", "baz"})
+
+	err := collection.Patch(ctx, foo.ID).IfChangeVector(foo.ChangeVector()).Set("Value", "qux").Apply()
+	require.True(t, errors.Is(err, ErrConcurrentTransaction))
+}
@@ -7,10 +7,12 @@ import (
 	"hash/crc32"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/altipla-consulting/errors"
 
+	"libs.altipla.consulting/geo"
 	"libs.altipla.consulting/rdb/api"
 )
 
@@ -66,19 +68,8 @@ func (q *Query) RQL() (string, map[string]interface{}) {
 		q = fn(q)
 	}
 
-	parts := []string{}
 	params := NewParams()
-
-	if q.index != "" {
-		parts = append(parts, "from index '"+q.index+"'")
-	} else {
-		parts = append(parts, "from "+q.golden.Collection())
-	}
-	if !q.root.isEmpty() {
-		var rql string
-		rql = q.root.RQL(params)
-		parts = append(parts, "where "+rql)
-	}
+	parts := q.sourceRQL(params)
 	if len(q.orders) > 0 {
 		for i, order := range q.orders {
 			if strings.HasPrefix(order, "-") {
@@ -109,6 +100,20 @@ func (q *Query) RQL() (string, map[string]interface{}) {
 	return strings.Join(parts, " "), params.values
 }
 
+// sourceRQL returns the from and where parts of the query.
+func (q *Query) sourceRQL(params *Params) []string {
+	parts := []string{}
+	if q.index != "" {
+		parts = append(parts, "from index '"+q.index+"'")
+	} else {
+		parts = append(parts, "from "+q.golden.Collection())
+	}
+	if !q.root.isEmpty() {
+		parts = append(parts, "where "+q.root.RQL(params))
+	}
+	return parts
+}
+
 func (q *Query) Offset(offset int64) *Query {
 	q.offset = offset
 	return q
@@ -169,6 +174,22 @@ func (q *Query) OrderByNumeric(field string) *Query {
 	return q
 }
 
+// OrderByDistance orders the results by the distance of their geo.Point field to
+// the point, the nearest first. Prefix the field with "-" to order the farthest first.
+func (q *Query) OrderByDistance(field string, point geo.Point) *Query {
+	var desc string
+	if strings.HasPrefix(field, "-") {
+		desc = "-"
+		field = field[1:]
+	}
+	q.checkOrderBy(field)
+
+	lat := strconv.FormatFloat(point.Lat, 'f', -1, 64)
+	lng := strconv.FormatFloat(point.Lng, 'f', -1, 64)
+	q.orders = append(q.orders, fmt.Sprintf("%sspatial.distance(%s, spatial.point(%s, %s))", desc, spatialPoint(field), lat, lng))
+	return q
+}
+
 func (q *Query) RandomOrder() *Query {
 	if len(q.orders) > 0 {
 		panic("cannot use RandomOrder after OrderBy")
@@ -236,6 +257,16 @@ func (q *Query) FilterBetween(field string, start, end interface{}) *Query {
 	return q
 }
 
+func (q *Query) FilterWithinRadius(field string, center geo.Point, km float64) *Query {
+	q.root.children = append(q.root.children, FilterWithinRadius(field, center, km))
+	return q
+}
+
+func (q *Query) FilterWithinWKT(field, shape string) *Query {
+	q.root.children = append(q.root.children, FilterWithinWKT(field, shape))
+	return q
+}
+
 func (q *Query) ForceStrongConsistency() *Query {
 	q.strongConsistency = true
 	return q
@@ -326,6 +357,11 @@ func (q *Query) GetAll(ctx context.Context, dest interface{}, opts ...IncludeOpt
 			if _, err := createModel(item.Interface(), result); err != nil {
 				return errors.Trace(err)
 			}
+			if len(q.selectFields) == 0 {
+				if err := sess.track(item.Interface()); err != nil {
+					return errors.Trace(err)
+				}
+			}
 			slice = reflect.Append(slice, item.Elem())
 		}
 		reflect.ValueOf(dest).Elem().Set(slice)
@@ -403,6 +439,11 @@ func (q *Query) First(ctx context.Context, dest interface{}, opts ...IncludeOpti
 			if _, err := createModel(dest, results.Results[0]); err != nil {
 				return errors.Trace(err)
 			}
+			if len(q.selectFields) == 0 {
+				if err := sess.track(dest); err != nil {
+					return errors.Trace(err)
+				}
+			}
 		}
 		return nil
 	case http.StatusNotFound:
@@ -462,45 +503,85 @@ func (q *Query) GetAllMetadata(ctx context.Context) ([]ModelMetadata, error) {
 	}
 }
 
+// GetAllIDs returns the IDs of all the results. They are streamed from the server
+// to avoid reading the full list of results in memory first.
 func (q *Query) GetAllIDs(ctx context.Context) ([]string, error) {
-	metadata, err := q.GetAllMetadata(ctx)
+	var ids []string
+	err := q.streamIDs(ctx, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	ids := make([]string, len(metadata))
-	for i, md := range metadata {
-		ids[i] = md.ID
-	}
 	return ids, nil
 }
 
+// Number of deletions sent in each request when deleting outside a session.
+const deleteEverythingChunkSize = 1024
+
+// DeleteEverything removes all the results of the query. Inside a session the
+// deletions are queued until the next SaveChanges call. Outside a session they
+// are sent in chunks, each one in its own transaction, so it is not atomic: an
+// error in the middle leaves the previous chunks deleted.
 func (q *Query) DeleteEverything(ctx context.Context) error {
 	// Is much quicker, though a dirty trick, to remove everything by its ID
 	// manually instead of using the Delete global operation. We use this method
 	// in every test so it's an important optimization.
 
-	metadata, err := q.GetAllMetadata(ctx)
+	if sess := SessionFromContext(ctx); sess != nil {
+		return errors.Trace(q.streamIDs(ctx, func(id string) error {
+			sess.actions = append(sess.actions, &deleteIDAction{id})
+			return nil
+		}))
+	}
+
+	chunkCtx, sess := q.db.NewSession(ctx)
+	err := q.streamIDs(ctx, func(id string) error {
+		sess.actions = append(sess.actions, &deleteIDAction{id})
+		if len(sess.actions) < deleteEverythingChunkSize {
+			return nil
+		}
+		if err := sess.SaveChanges(chunkCtx); err != nil {
+			return errors.Trace(err)
+		}
+		chunkCtx, sess = q.db.NewSession(ctx)
+		return nil
+	})
 	if err != nil {
 		return errors.Trace(err)
 	}
-	ids := make([]string, len(metadata))
-	for i, md := range metadata {
-		ids[i] = md.ID
-	}
+	return errors.Trace(sess.SaveChanges(chunkCtx))
+}
 
-	sess := SessionFromContext(ctx)
-	if sess == nil {
-		ctx, sess := q.db.NewSession(ctx)
-		for _, md := range metadata {
-			sess.actions = append(sess.actions, &deleteIDAction{md.ID})
+// streamIDs calls fn with the ID of each result as they are read from the server.
+// Streamed queries never wait for stale indexes, so if strong consistency is
+// requested it counts the results first to wait for the index.
+func (q *Query) streamIDs(ctx context.Context, fn func(id string) error) error {
+	if q.strongConsistency || q.db.strongConsistency {
+		if _, err := q.Clone().Count(ctx); err != nil {
+			return errors.Trace(err)
 		}
-		return errors.Trace(sess.SaveChanges(ctx))
 	}
 
-	for _, md := range metadata {
-		sess.actions = append(sess.actions, &deleteIDAction{md.ID})
+	it, err := q.stream(ctx, map[string]interface{}{"metadataOnly": "true"})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer it.Close()
+
+	for {
+		result, err := it.nextResult()
+		if err != nil {
+			if errors.Is(err, ErrDone) {
+				return nil
+			}
+			return errors.Trace(err)
+		}
+		if err := fn(result.Metadata("@id")); err != nil {
+			return errors.Trace(err)
+		}
 	}
-	return nil
 }
 
 func (q *Query) Select(fields ...string) *Query {
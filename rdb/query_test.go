@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/geo"
 )
 
 type FooQueryModel struct {
@@ -340,6 +342,28 @@ func TestQueryOrderDefaultForNumericFields(t *testing.T) {
 	require.Equal(t, models[3].ID, "foo-queries-numeric/1")
 }
 
+func TestQueryOrderByDistanceRQL(t *testing.T) {
+	db := &Database{}
+	collection := db.Collection(new(FooQueryModel))
+
+	madrid := geo.Point{Lng: -3.7, Lat: 40.4}
+	q := collection.
+		FilterWithinRadius("Location", madrid, 25.5).
+		OrderByDistance("Location", madrid)
+
+	sql, params := q.RQL()
+	require.Equal(t, sql, `from FooQueryModels where spatial.within(spatial.point(Location.Lat, Location.Lng), spatial.circle($p0, $p1, $p2, 'Kilometers')) order by spatial.distance(spatial.point(Location.Lat, Location.Lng), spatial.point(40.4, -3.7))`)
+	require.Equal(t, params, map[string]interface{}{
+		"p0": 25.5,
+		"p1": 40.4,
+		"p2": -3.7,
+	})
+
+	collection = db.Collection(new(FooQueryModel))
+	sql, _ = collection.OrderByDistance("-Location", madrid).RQL()
+	require.Equal(t, sql, `from FooQueryModels order by spatial.distance(spatial.point(Location.Lat, Location.Lng), spatial.point(40.4, -3.7)) desc`)
+}
+
 func TestQueryWithInclude(t *testing.T) {
 	db := initQueryTestbed(t)
 	ctx, sess := db.NewSession(context.Background())
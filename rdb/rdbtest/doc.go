@@ -0,0 +1,7 @@
+// Package rdbtest implements an in-memory fake of a RavenDB server to run the
+// tests of rdb applications without external services.
+//
+// It only supports a subset of the REST API: documents, batches, simple RQL
+// queries over collections, counters and includes. Indexes can be created but
+// they cannot be queried, use a real server for those tests.
+package rdbtest
@@ -0,0 +1,888 @@
+package rdbtest
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// rqlQuery is a parsed RQL query with its parameters already resolved.
+type rqlQuery struct {
+	collection string
+	index      string
+	where      rqlExpr
+	orders     []rqlOrder
+	selects    []string
+	includes   []string
+	limit      int64
+	offset     int64
+}
+
+type rqlOrder struct {
+	field  string
+	kind   string
+	desc   bool
+	random bool
+}
+
+type rqlExpr interface {
+	match(doc map[string]interface{}) bool
+}
+
+type rqlToken struct {
+	text  string
+	quote bool
+}
+
+func tokenizeRQL(rql string) ([]rqlToken, error) {
+	var tokens []rqlToken
+	runes := []rune(rql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.Errorf("unterminated string in query: %s", rql)
+			}
+			tokens = append(tokens, rqlToken{text: string(runes[i+1 : end]), quote: true})
+			i = end + 1
+
+		case strings.ContainsRune("(),", r):
+			tokens = append(tokens, rqlToken{text: string(r)})
+			i++
+
+		case strings.ContainsRune("=!<>", r):
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, rqlToken{text: string(runes[i : i+2])})
+				i += 2
+			} else {
+				tokens = append(tokens, rqlToken{text: string(r)})
+				i++
+			}
+
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("(),=!<>'\"", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, rqlToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type rqlParser struct {
+	tokens []rqlToken
+	pos    int
+	params map[string]interface{}
+	exact  bool
+}
+
+func parseRQL(rql string, params map[string]interface{}) (*rqlQuery, error) {
+	tokens, err := tokenizeRQL(rql)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p := &rqlParser{tokens: tokens, params: params}
+
+	q := new(rqlQuery)
+	q.limit = -1
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.peekKeyword("index") {
+		p.pos++
+		q.index = p.next().text
+	} else {
+		q.collection = p.next().text
+	}
+
+	for p.pos < len(p.tokens) {
+		switch {
+		case p.peekKeyword("where"):
+			p.pos++
+			q.where, err = p.parseOr()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+		case p.peekKeyword("order"):
+			p.pos++
+			if err := p.expectKeyword("by"); err != nil {
+				return nil, errors.Trace(err)
+			}
+			for {
+				order, err := p.parseOrder()
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				q.orders = append(q.orders, order)
+				if !p.peek(",") {
+					break
+				}
+				p.pos++
+			}
+
+		case p.peekKeyword("select"):
+			p.pos++
+			q.selects = p.parseList()
+
+		case p.peekKeyword("include"):
+			p.pos++
+			q.includes = p.parseList()
+
+		case p.peekKeyword("limit"):
+			p.pos++
+			q.limit, err = strconv.ParseInt(p.next().text, 10, 64)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+		case p.peekKeyword("offset"):
+			p.pos++
+			q.offset, err = strconv.ParseInt(p.next().text, 10, 64)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+
+		default:
+			return nil, errors.Errorf("unsupported query clause %q: %s", p.tokens[p.pos].text, rql)
+		}
+	}
+
+	return q, nil
+}
+
+func (p *rqlParser) next() rqlToken {
+	if p.pos >= len(p.tokens) {
+		return rqlToken{}
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *rqlParser) peek(text string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quote && p.tokens[p.pos].text == text
+}
+
+func (p *rqlParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quote && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *rqlParser) expect(text string) error {
+	if !p.peek(text) {
+		return errors.Errorf("expected %q in query at position %d", text, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *rqlParser) expectKeyword(keyword string) error {
+	if !p.peekKeyword(keyword) {
+		return errors.Errorf("expected %q in query at position %d", keyword, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *rqlParser) parseList() []string {
+	var list []string
+	for {
+		list = append(list, p.next().text)
+		if !p.peek(",") {
+			return list
+		}
+		p.pos++
+	}
+}
+
+func (p *rqlParser) parseOrder() (rqlOrder, error) {
+	field := p.next().text
+	if strings.EqualFold(field, "random") {
+		if err := p.expect("("); err != nil {
+			return rqlOrder{}, errors.Trace(err)
+		}
+		if err := p.expect(")"); err != nil {
+			return rqlOrder{}, errors.Trace(err)
+		}
+		return rqlOrder{random: true}, nil
+	}
+	if p.peek("(") {
+		return rqlOrder{}, errors.Errorf("unsupported order function: %s", field)
+	}
+
+	order := rqlOrder{field: field}
+	if p.peekKeyword("as") {
+		p.pos++
+		order.kind = strings.ToLower(p.next().text)
+	}
+	switch {
+	case p.peekKeyword("desc"):
+		p.pos++
+		order.desc = true
+	case p.peekKeyword("asc"):
+		p.pos++
+	}
+	return order, nil
+}
+
+func (p *rqlParser) parseOr() (rqlExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		left = &orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *rqlParser) parseAnd() (rqlExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		left = &andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *rqlParser) parseTerm() (rqlExpr, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		expr, err := p.parseTerm()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &notExpr{expr}, nil
+	}
+	if p.peek("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return expr, errors.Trace(p.expect(")"))
+	}
+
+	name := p.next().text
+	if name == "" {
+		return nil, errors.Errorf("unexpected end of query")
+	}
+	if p.peek("(") && !strings.EqualFold(name, "id") {
+		return p.parseFunction(name)
+	}
+	if strings.EqualFold(name, "id") && p.peek("(") {
+		p.pos++
+		if err := p.expect(")"); err != nil {
+			return nil, errors.Trace(err)
+		}
+		name = "id()"
+	}
+
+	switch {
+	case p.peekKeyword("in"):
+		p.pos++
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &inExpr{field: name, values: values}, nil
+
+	case p.peekKeyword("all"):
+		p.pos++
+		if err := p.expectKeyword("in"); err != nil {
+			return nil, errors.Trace(err)
+		}
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &inExpr{field: name, values: values, all: true}, nil
+
+	case p.peekKeyword("between"):
+		p.pos++
+		from, err := p.parseValue()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := p.expectKeyword("and"); err != nil {
+			return nil, errors.Trace(err)
+		}
+		to, err := p.parseValue()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &betweenExpr{name, from, to, p.exact}, nil
+	}
+
+	op := p.next().text
+	switch op {
+	case "=", "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, errors.Errorf("unsupported query operator %q", op)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &compareExpr{name, op, value, p.exact}, nil
+}
+
+func (p *rqlParser) parseFunction(name string) (rqlExpr, error) {
+	p.pos++
+
+	var expr rqlExpr
+	switch strings.ToLower(name) {
+	case "exact":
+		p.exact = true
+		inner, err := p.parseOr()
+		p.exact = false
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		expr = inner
+
+	case "exists":
+		expr = &existsExpr{p.next().text}
+
+	case "startswith", "endswith":
+		field := p.next().text
+		if err := p.expect(","); err != nil {
+			return nil, errors.Trace(err)
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		str, _ := value.(string)
+		expr = &affixExpr{field, strings.ToLower(str), strings.ToLower(name) == "startswith"}
+
+	case "search":
+		field := p.next().text
+		if err := p.expect(","); err != nil {
+			return nil, errors.Trace(err)
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		str, _ := value.(string)
+		search := &searchExpr{field: field, terms: strings.Fields(strings.ToLower(str))}
+		if p.peek(",") {
+			p.pos++
+			search.all = strings.EqualFold(p.next().text, "and")
+		}
+		expr = search
+
+	default:
+		return nil, errors.Errorf("unsupported query function: %s", name)
+	}
+
+	return expr, errors.Trace(p.expect(")"))
+}
+
+func (p *rqlParser) parseValues() ([]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var values []interface{}
+	for !p.peek(")") {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if list, ok := value.([]interface{}); ok {
+			values = append(values, list...)
+		} else {
+			values = append(values, value)
+		}
+		if p.peek(",") {
+			p.pos++
+		}
+	}
+	p.pos++
+	return values, nil
+}
+
+func (p *rqlParser) parseValue() (interface{}, error) {
+	token := p.next()
+	if token.quote {
+		return token.text, nil
+	}
+	switch {
+	case strings.HasPrefix(token.text, "$"):
+		value, ok := p.params[token.text[1:]]
+		if !ok {
+			return nil, errors.Errorf("query parameter not found: %s", token.text)
+		}
+		return value, nil
+	case strings.EqualFold(token.text, "null"):
+		return nil, nil
+	case strings.EqualFold(token.text, "true"):
+		return true, nil
+	case strings.EqualFold(token.text, "false"):
+		return false, nil
+	}
+	n, err := strconv.ParseFloat(token.text, 64)
+	if err != nil {
+		return nil, errors.Errorf("unsupported query value: %s", token.text)
+	}
+	return n, nil
+}
+
+type andExpr struct {
+	left, right rqlExpr
+}
+
+func (expr *andExpr) match(doc map[string]interface{}) bool {
+	return expr.left.match(doc) && expr.right.match(doc)
+}
+
+type orExpr struct {
+	left, right rqlExpr
+}
+
+func (expr *orExpr) match(doc map[string]interface{}) bool {
+	return expr.left.match(doc) || expr.right.match(doc)
+}
+
+type notExpr struct {
+	expr rqlExpr
+}
+
+func (expr *notExpr) match(doc map[string]interface{}) bool {
+	return !expr.expr.match(doc)
+}
+
+type compareExpr struct {
+	field string
+	op    string
+	value interface{}
+	exact bool
+}
+
+func (expr *compareExpr) match(doc map[string]interface{}) bool {
+	values := fieldValues(doc, expr.field)
+	if expr.op == "!=" {
+		for _, value := range values {
+			if cmp, ok := compareValues(value, expr.value, expr.exact); ok && cmp == 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		cmp, ok := compareValues(value, expr.value, expr.exact)
+		if !ok {
+			continue
+		}
+		switch expr.op {
+		case "=", "==":
+			if cmp == 0 {
+				return true
+			}
+		case "<":
+			if cmp < 0 {
+				return true
+			}
+		case "<=":
+			if cmp <= 0 {
+				return true
+			}
+		case ">":
+			if cmp > 0 {
+				return true
+			}
+		case ">=":
+			if cmp >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type inExpr struct {
+	field  string
+	values []interface{}
+	all    bool
+}
+
+func (expr *inExpr) match(doc map[string]interface{}) bool {
+	values := fieldValues(doc, expr.field)
+	for _, want := range expr.values {
+		var found bool
+		for _, value := range values {
+			if cmp, ok := compareValues(value, want, false); ok && cmp == 0 {
+				found = true
+				break
+			}
+		}
+		if found && !expr.all {
+			return true
+		}
+		if !found && expr.all {
+			return false
+		}
+	}
+	return expr.all && len(expr.values) > 0
+}
+
+type betweenExpr struct {
+	field    string
+	from, to interface{}
+	exact    bool
+}
+
+func (expr *betweenExpr) match(doc map[string]interface{}) bool {
+	for _, value := range fieldValues(doc, expr.field) {
+		from, ok := compareValues(value, expr.from, expr.exact)
+		if !ok {
+			continue
+		}
+		to, ok := compareValues(value, expr.to, expr.exact)
+		if !ok {
+			continue
+		}
+		if from >= 0 && to <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type existsExpr struct {
+	field string
+}
+
+func (expr *existsExpr) match(doc map[string]interface{}) bool {
+	_, ok := lookupField(doc, expr.field)
+	return ok
+}
+
+type affixExpr struct {
+	field  string
+	affix  string
+	prefix bool
+}
+
+func (expr *affixExpr) match(doc map[string]interface{}) bool {
+	for _, value := range fieldValues(doc, expr.field) {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		str = strings.ToLower(str)
+		if expr.prefix && strings.HasPrefix(str, expr.affix) {
+			return true
+		}
+		if !expr.prefix && strings.HasSuffix(str, expr.affix) {
+			return true
+		}
+	}
+	return false
+}
+
+type searchExpr struct {
+	field string
+	terms []string
+	all   bool
+}
+
+func (expr *searchExpr) match(doc map[string]interface{}) bool {
+	var words []string
+	for _, value := range fieldValues(doc, expr.field) {
+		if str, ok := value.(string); ok {
+			words = append(words, strings.FieldsFunc(strings.ToLower(str), func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			})...)
+		}
+	}
+
+	for _, term := range expr.terms {
+		found := matchSearchTerm(words, term)
+		if found && !expr.all {
+			return true
+		}
+		if !found && expr.all {
+			return false
+		}
+	}
+	return expr.all && len(expr.terms) > 0
+}
+
+func matchSearchTerm(words []string, term string) bool {
+	term = strings.ReplaceAll(term, `\`, "")
+	for _, word := range words {
+		switch {
+		case strings.HasPrefix(term, "*") && strings.HasSuffix(term, "*") && len(term) > 1:
+			if strings.Contains(word, term[1:len(term)-1]) {
+				return true
+			}
+		case strings.HasSuffix(term, "*"):
+			if strings.HasPrefix(word, term[:len(term)-1]) {
+				return true
+			}
+		case strings.HasPrefix(term, "*"):
+			if strings.HasSuffix(word, term[1:]) {
+				return true
+			}
+		case word == term:
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField returns the value of a field path like "Address.City" in the document.
+// Arrays in the middle of the path return the values of all their items.
+func lookupField(doc map[string]interface{}, field string) (interface{}, bool) {
+	if field == "id()" {
+		return documentID(doc), true
+	}
+
+	var current interface{} = doc
+	for _, part := range strings.Split(field, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+
+		case []interface{}:
+			var items []interface{}
+			for _, item := range value {
+				if m, ok := item.(map[string]interface{}); ok {
+					if next, ok := m[part]; ok {
+						items = append(items, next)
+					}
+				}
+			}
+			current = items
+
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// fieldValues returns the values to compare of the field. Arrays match if any of
+// their items match, and a missing field is equal to null.
+func fieldValues(doc map[string]interface{}, field string) []interface{} {
+	value, _ := lookupField(doc, field)
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+func documentID(doc map[string]interface{}) string {
+	md, _ := doc["@metadata"].(map[string]interface{})
+	id, _ := md["@id"].(string)
+	return id
+}
+
+// compareValues returns the order of the two values, and false if they cannot
+// be compared because they have different types.
+func compareValues(a, b interface{}, exact bool) (int, bool) {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		if !exact {
+			a, b = strings.ToLower(a), strings.ToLower(b)
+		}
+		return strings.Compare(a, b), true
+
+	case bool:
+		b, ok := b.(bool)
+		switch {
+		case !ok:
+			return 0, false
+		case a == b:
+			return 0, true
+		case !a:
+			return -1, true
+		}
+		return 1, true
+	}
+
+	fa, ok := toNumber(a)
+	if !ok {
+		return 0, false
+	}
+	fb, ok := toNumber(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// sortDocuments orders the documents following the order clauses of the query.
+func sortDocuments(docs []map[string]interface{}, orders []rqlOrder) {
+	for _, order := range orders {
+		if order.random {
+			rand.Shuffle(len(docs), func(i, j int) {
+				docs[i], docs[j] = docs[j], docs[i]
+			})
+			return
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, order := range orders {
+			a, _ := lookupField(docs[i], order.field)
+			b, _ := lookupField(docs[j], order.field)
+			cmp := compareOrder(a, b, order.kind)
+			if cmp == 0 {
+				continue
+			}
+			if order.desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+func compareOrder(a, b interface{}, kind string) int {
+	switch kind {
+	case "long", "double":
+		fa, oka := toNumber(a)
+		fb, okb := toNumber(b)
+		switch {
+		case !oka || !okb:
+			return compareMissing(oka, okb)
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+
+	case "alphanumeric":
+		return compareAlphanumeric(orderString(a), orderString(b))
+
+	case "string":
+		return strings.Compare(orderString(a), orderString(b))
+	}
+
+	if cmp, ok := compareValues(a, b, false); ok {
+		return cmp
+	}
+	return strings.Compare(orderString(a), orderString(b))
+}
+
+func compareMissing(oka, okb bool) int {
+	switch {
+	case oka == okb:
+		return 0
+	case !oka:
+		return -1
+	}
+	return 1
+}
+
+func orderString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if str, ok := value.(string); ok {
+		return strings.ToLower(str)
+	}
+	return strings.ToLower(fmt.Sprintf("%v", value))
+}
+
+// compareAlphanumeric compares the numbers inside the strings by their value
+// instead of their digits, e.g. "Foo2" < "Foo10".
+func compareAlphanumeric(a, b string) int {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, _ := strconv.ParseInt(da, 10, 64)
+			nb, _ := strconv.ParseInt(db, 10, 64)
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
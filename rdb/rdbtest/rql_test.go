@@ -0,0 +1,94 @@
+package rdbtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testDocument(id string, data map[string]interface{}) map[string]interface{} {
+	data["@metadata"] = map[string]interface{}{"@id": id}
+	return data
+}
+
+func TestParseRQL(t *testing.T) {
+	q, err := parseRQL("from Foos where Bar = $p0 order by Baz desc, Qux as long select Bar include Ref limit 10 offset 5", map[string]interface{}{"p0": "bar"})
+	require.NoError(t, err)
+
+	require.Equal(t, q.collection, "Foos")
+	require.Equal(t, q.orders, []rqlOrder{
+		{field: "Baz", desc: true},
+		{field: "Qux", kind: "long"},
+	})
+	require.Equal(t, q.selects, []string{"Bar"})
+	require.Equal(t, q.includes, []string{"Ref"})
+	require.EqualValues(t, q.limit, 10)
+	require.EqualValues(t, q.offset, 5)
+}
+
+func TestParseRQLIndex(t *testing.T) {
+	q, err := parseRQL("from index 'Foos/ByBar'", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, q.index, "Foos/ByBar")
+	require.EqualValues(t, q.limit, -1)
+}
+
+func TestParseRQLUnsupported(t *testing.T) {
+	_, err := parseRQL("from Foos group by Bar", nil)
+	require.Error(t, err)
+}
+
+func TestRQLMatch(t *testing.T) {
+	doc := testDocument("foos/1", map[string]interface{}{
+		"Name":  "Foo Bar",
+		"Age":   float64(30),
+		"Tags":  []interface{}{"a", "b"},
+		"Empty": nil,
+		"Sub":   map[string]interface{}{"Value": "sub"},
+	})
+
+	tests := []struct {
+		rql     string
+		params  map[string]interface{}
+		matches bool
+	}{
+		{"where Name = $p0", map[string]interface{}{"p0": "foo bar"}, true},
+		{"where exact(Name = $p0)", map[string]interface{}{"p0": "foo bar"}, false},
+		{"where Name != 'Foo Bar'", nil, false},
+		{"where Age > 20 and Age <= 30", nil, true},
+		{"where Age < 20 or Name = 'Foo Bar'", nil, true},
+		{"where not (Age = 30)", nil, false},
+		{"where Age between 10 and 20", nil, false},
+		{"where Tags in ($p0)", map[string]interface{}{"p0": []interface{}{"b", "c"}}, true},
+		{"where Tags all in ($p0)", map[string]interface{}{"p0": []interface{}{"a", "c"}}, false},
+		{"where Empty = null", nil, true},
+		{"where exists(Sub.Value)", nil, true},
+		{"where exists(Missing)", nil, false},
+		{"where startsWith(Name, 'foo')", nil, true},
+		{"where endsWith(Name, 'foo')", nil, false},
+		{"where search(Name, 'bar*')", nil, true},
+		{"where id() = 'FOOS/1'", nil, true},
+	}
+	for _, test := range tests {
+		q, err := parseRQL("from Foos "+test.rql, test.params)
+		require.NoError(t, err, test.rql)
+		require.Equal(t, q.where.match(doc), test.matches, test.rql)
+	}
+}
+
+func TestSortDocuments(t *testing.T) {
+	docs := []map[string]interface{}{
+		testDocument("foos/1", map[string]interface{}{"Name": "item10", "Age": float64(2)}),
+		testDocument("foos/2", map[string]interface{}{"Name": "item9", "Age": float64(1)}),
+		testDocument("foos/3", map[string]interface{}{"Name": "item9", "Age": float64(3)}),
+	}
+
+	sortDocuments(docs, []rqlOrder{{field: "Name", kind: "alphanumeric"}, {field: "Age", desc: true}})
+
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, documentID(doc))
+	}
+	require.Equal(t, ids, []string{"foos/3", "foos/2", "foos/1"})
+}
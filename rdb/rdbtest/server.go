@@ -0,0 +1,488 @@
+package rdbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb"
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Server is an in-memory RavenDB server. Databases are created automatically the
+// first time they are used.
+type Server struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	databases map[string]*database
+	etag      int64
+}
+
+// NewServer starts a new empty server. It should be closed after using it.
+func NewServer() *Server {
+	srv := &Server{
+		databases: make(map[string]*database),
+	}
+	srv.server = httptest.NewServer(http.HandlerFunc(srv.serveHTTP))
+	return srv
+}
+
+// URL returns the address of the server to open the databases with rdb.Open.
+func (srv *Server) URL() string {
+	return srv.server.URL
+}
+
+// Close stops the server and removes all its data.
+func (srv *Server) Close() {
+	srv.server.Close()
+}
+
+// Open starts a new server for the test and opens the database in it. The server
+// is closed when the test finishes.
+func Open(t *testing.T, dbname string, opts ...rdb.OpenOption) *rdb.Database {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	db, err := rdb.Open(srv.URL(), dbname, opts...)
+	require.NoError(t, err)
+	return db
+}
+
+func (srv *Server) database(name string) *database {
+	if srv.databases[name] == nil {
+		srv.databases[name] = newDatabase(name)
+	}
+	return srv.databases[name]
+}
+
+func (srv *Server) nextEtag() int64 {
+	srv.etag++
+	return srv.etag
+}
+
+func (srv *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/admin/databases":
+		srv.serveAdminDatabases(w, r)
+
+	case r.URL.Path == "/topology":
+		emitJSON(w, http.StatusOK, &api.Topology{
+			Etag: 1,
+			Nodes: []*api.ServerNode{
+				{
+					URL:        srv.server.URL,
+					ClusterTag: "A",
+					Database:   r.URL.Query().Get("name"),
+					ServerRole: "Member",
+				},
+			},
+		})
+
+	case strings.HasPrefix(r.URL.Path, "/databases/"):
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/databases/"), "/", 2)
+		if len(parts) != 2 {
+			emitError(w, http.StatusNotFound, "Raven.Client.Exceptions.RouteNotFoundException", "route not found: "+r.URL.Path)
+			return
+		}
+		srv.serveDatabase(w, r, srv.database(parts[0]), parts[1])
+
+	default:
+		emitNotImplemented(w, r)
+	}
+}
+
+func (srv *Server) serveAdminDatabases(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		db := srv.database(r.URL.Query().Get("name"))
+		emitJSON(w, http.StatusOK, &api.Database{
+			DatabaseName: db.name,
+			Indexes:      db.indexes,
+			Revisions:    db.revisions,
+		})
+
+	case http.MethodPut:
+		srv.database(r.URL.Query().Get("name"))
+		emitJSON(w, http.StatusCreated, map[string]interface{}{"Name": r.URL.Query().Get("name")})
+
+	default:
+		emitNotImplemented(w, r)
+	}
+}
+
+func (srv *Server) serveDatabase(w http.ResponseWriter, r *http.Request, db *database, route string) {
+	switch {
+	case route == "docs" && r.Method == http.MethodGet:
+		srv.getDocs(w, r, db)
+	case route == "docs" && r.Method == http.MethodPost:
+		srv.getDocsMulti(w, r, db)
+	case route == "docs" && r.Method == http.MethodPut:
+		srv.putDoc(w, r, db)
+	case route == "docs" && r.Method == http.MethodDelete:
+		srv.deleteDoc(w, r, db)
+	case route == "bulk_docs" && r.Method == http.MethodPost:
+		srv.batch(w, r, db)
+	case route == "queries" && r.Method == http.MethodPost:
+		srv.query(w, r, db)
+	case route == "streams/queries" && r.Method == http.MethodPost:
+		srv.query(w, r, db)
+	case route == "counters" && r.Method == http.MethodGet:
+		srv.getCounters(w, r, db)
+	case route == "counters" && r.Method == http.MethodPost:
+		srv.updateCounters(w, r, db)
+	case route == "identity/seed" && r.Method == http.MethodPost:
+		srv.seedIdentity(w, r, db)
+	case route == "debug/identities" && r.Method == http.MethodGet:
+		emitJSON(w, http.StatusOK, db.identities)
+	case route == "admin/indexes" && r.Method == http.MethodPut:
+		srv.putIndexes(w, r, db)
+	case route == "admin/revisions/config" && r.Method == http.MethodPost:
+		db.revisions = new(api.Revisions)
+		if !decodeJSON(w, r, db.revisions) {
+			return
+		}
+		emitJSON(w, http.StatusOK, map[string]interface{}{"RaftCommandIndex": srv.nextEtag()})
+	case route == "admin/expiration/config" && r.Method == http.MethodPost:
+		emitJSON(w, http.StatusOK, map[string]interface{}{"RaftCommandIndex": srv.nextEtag()})
+	default:
+		emitNotImplemented(w, r)
+	}
+}
+
+func (srv *Server) getDocs(w http.ResponseWriter, r *http.Request, db *database) {
+	ids := r.URL.Query()["id"]
+	results := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		doc := db.get(id)
+		if doc == nil {
+			if len(ids) == 1 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			continue
+		}
+		results[i] = doc.result(db)
+		if r.URL.Query().Get("metadataOnly") == "true" {
+			results[i] = map[string]interface{}{"@metadata": doc.resultMetadata(db)}
+		}
+	}
+	emitResults(w, db, results, r.URL.Query()["include"])
+}
+
+func (srv *Server) getDocsMulti(w http.ResponseWriter, r *http.Request, db *database) {
+	req := new(api.DocsRequest)
+	if !decodeJSON(w, r, req) {
+		return
+	}
+
+	results := make([]map[string]interface{}, len(req.IDs))
+	for i, id := range req.IDs {
+		if doc := db.get(id); doc != nil {
+			results[i] = doc.result(db)
+		}
+	}
+	emitResults(w, db, results, r.URL.Query()["include"])
+}
+
+func emitResults(w http.ResponseWriter, db *database, results []map[string]interface{}, include []string) {
+	var found []map[string]interface{}
+	for _, result := range results {
+		if result != nil {
+			found = append(found, result)
+		}
+	}
+	emitJSON(w, http.StatusOK, map[string]interface{}{
+		"Results":  results,
+		"Includes": db.includes(found, include),
+	})
+}
+
+func (srv *Server) putDoc(w http.ResponseWriter, r *http.Request, db *database) {
+	body := make(map[string]interface{})
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	var changeVector *string
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		changeVector = &ifMatch
+	}
+	doc, err := db.put(r.URL.Query().Get("id"), changeVector, body, srv.nextEtag())
+	if err != nil {
+		emitConflict(w, err)
+		return
+	}
+	emitJSON(w, http.StatusCreated, map[string]interface{}{
+		"Id":           doc.id,
+		"ChangeVector": doc.changeVector,
+	})
+}
+
+func (srv *Server) deleteDoc(w http.ResponseWriter, r *http.Request, db *database) {
+	var changeVector *string
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		changeVector = &ifMatch
+	}
+	if _, err := db.delete(r.URL.Query().Get("id"), changeVector); err != nil {
+		emitConflict(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type batchCommand struct {
+	ID           string `json:"Id"`
+	ChangeVector *string
+	Document     map[string]interface{}
+	IDPrefixed   bool `json:"IdPrefixed"`
+	Type         string
+}
+
+func (srv *Server) batch(w http.ResponseWriter, r *http.Request, db *database) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		emitNotImplemented(w, r)
+		return
+	}
+	req := new(struct {
+		Commands []*batchCommand
+	})
+	if !decodeJSON(w, r, req) {
+		return
+	}
+
+	// Batches are transactional, restore the previous state if any command fails.
+	snapshot := db.snapshot()
+	results := make([]map[string]interface{}, 0, len(req.Commands))
+	for _, cmd := range req.Commands {
+		switch cmd.Type {
+		case "PUT":
+			doc, err := db.put(cmd.ID, cmd.ChangeVector, cmd.Document, srv.nextEtag())
+			if err != nil {
+				db.restore(snapshot)
+				emitConflict(w, err)
+				return
+			}
+			results = append(results, map[string]interface{}{
+				"Type":           "PUT",
+				"@id":            doc.id,
+				"@collection":    doc.collection,
+				"@change-vector": doc.changeVector,
+				"@last-modified": doc.lastModified.Format(api.DateTimeFormat),
+			})
+
+		case "DELETE":
+			if cmd.IDPrefixed {
+				db.deletePrefix(cmd.ID)
+				results = append(results, map[string]interface{}{
+					"Type": "DELETE",
+					"@id":  cmd.ID,
+				})
+				continue
+			}
+			deleted, err := db.delete(cmd.ID, cmd.ChangeVector)
+			if err != nil {
+				db.restore(snapshot)
+				emitConflict(w, err)
+				return
+			}
+			results = append(results, map[string]interface{}{
+				"Type":    "DELETE",
+				"@id":     cmd.ID,
+				"Deleted": deleted,
+			})
+
+		default:
+			db.restore(snapshot)
+			emitError(w, http.StatusNotImplemented, "rdbtest.NotImplementedException", fmt.Sprintf("batch command %q is not implemented in rdbtest", cmd.Type))
+			return
+		}
+	}
+
+	emitJSON(w, http.StatusCreated, map[string]interface{}{"Results": results})
+}
+
+func (srv *Server) query(w http.ResponseWriter, r *http.Request, db *database) {
+	req := new(api.Query)
+	if !decodeJSON(w, r, req) {
+		return
+	}
+	q, err := parseRQL(req.Query, req.QueryParameters)
+	if err != nil {
+		emitError(w, http.StatusBadRequest, "Raven.Client.Exceptions.InvalidQueryException", err.Error())
+		return
+	}
+	if q.index != "" {
+		emitError(w, http.StatusNotFound, "Raven.Client.Exceptions.Documents.Indexes.IndexDoesNotExistException", "rdbtest cannot query indexes: "+q.index)
+		return
+	}
+
+	results := db.query(q)
+	total := len(results)
+	includes := db.includes(results, q.includes)
+
+	start := int(q.offset)
+	if start > len(results) {
+		start = len(results)
+	}
+	results = results[start:]
+	if q.limit >= 0 && int(q.limit) < len(results) {
+		results = results[:q.limit]
+	}
+
+	for i, result := range results {
+		switch {
+		case r.URL.Query().Get("metadataOnly") == "true":
+			results[i] = map[string]interface{}{"@metadata": result["@metadata"]}
+		case len(q.selects) > 0:
+			projection := make(map[string]interface{})
+			for _, field := range q.selects {
+				if value, ok := lookupField(result, field); ok {
+					projection[field] = value
+				}
+			}
+			projection["@metadata"] = map[string]interface{}{
+				"@id":         documentID(result),
+				"@projection": true,
+			}
+			results[i] = projection
+		}
+	}
+	if results == nil {
+		results = []map[string]interface{}{}
+	}
+
+	emitJSON(w, http.StatusOK, map[string]interface{}{
+		"Results":        results,
+		"Includes":       includes,
+		"TotalResults":   total,
+		"SkippedResults": 0,
+		"DurationInMs":   0,
+		"IndexName":      "collection/" + q.collection,
+		"IsStale":        false,
+	})
+}
+
+func (srv *Server) getCounters(w http.ResponseWriter, r *http.Request, db *database) {
+	docID := r.URL.Query().Get("docId")
+	counters := db.counters[strings.ToLower(docID)]
+
+	names := r.URL.Query()["counter"]
+	if len(names) == 0 {
+		for name := range counters {
+			names = append(names, name)
+		}
+	}
+	result := new(api.Counters)
+	for _, name := range names {
+		if value, ok := counters[name]; ok {
+			result.Counters = append(result.Counters, &api.Counter{
+				DocumentID:  docID,
+				CounterName: name,
+				TotalValue:  value,
+			})
+		}
+	}
+	emitJSON(w, http.StatusOK, result)
+}
+
+func (srv *Server) updateCounters(w http.ResponseWriter, r *http.Request, db *database) {
+	req := new(api.CounterOperations)
+	if !decodeJSON(w, r, req) {
+		return
+	}
+
+	result := new(api.Counters)
+	for _, doc := range req.Documents {
+		if db.get(doc.DocumentID) == nil {
+			emitError(w, http.StatusInternalServerError, "Raven.Client.Exceptions.Documents.DocumentDoesNotExistException", fmt.Sprintf("document '%s' does not exist", doc.DocumentID))
+			return
+		}
+
+		id := strings.ToLower(doc.DocumentID)
+		if db.counters[id] == nil {
+			db.counters[id] = make(map[string]int64)
+		}
+		for _, op := range doc.Operations {
+			switch op.Type {
+			case api.CounterOperationTypeIncrement:
+				db.counters[id][op.CounterName] += op.Delta
+			case api.CounterOperationTypeDelete:
+				delete(db.counters[id], op.CounterName)
+				continue
+			}
+			if value, ok := db.counters[id][op.CounterName]; ok {
+				result.Counters = append(result.Counters, &api.Counter{
+					DocumentID:  doc.DocumentID,
+					CounterName: op.CounterName,
+					TotalValue:  value,
+				})
+			}
+		}
+	}
+	emitJSON(w, http.StatusOK, result)
+}
+
+func (srv *Server) seedIdentity(w http.ResponseWriter, r *http.Request, db *database) {
+	value, err := strconv.ParseInt(r.URL.Query().Get("value"), 10, 64)
+	if err != nil {
+		emitError(w, http.StatusBadRequest, "System.ArgumentException", err.Error())
+		return
+	}
+	name := strings.TrimSuffix(r.URL.Query().Get("name"), "|") + "|"
+	if value > db.identities[name] {
+		db.identities[name] = value
+	}
+	emitJSON(w, http.StatusOK, map[string]interface{}{"NewSeedValue": db.identities[name]})
+}
+
+func (srv *Server) putIndexes(w http.ResponseWriter, r *http.Request, db *database) {
+	req := new(api.IndexesRequest)
+	if !decodeJSON(w, r, req) {
+		return
+	}
+	for _, index := range req.Indexes {
+		db.indexes[index.Name] = index
+	}
+	emitJSON(w, http.StatusCreated, map[string]interface{}{"Results": []interface{}{}})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dest interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		emitError(w, http.StatusBadRequest, "System.IO.InvalidDataException", errors.Trace(err).Error())
+		return false
+	}
+	return true
+}
+
+func emitJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func emitError(w http.ResponseWriter, status int, errorType, message string) {
+	emitJSON(w, status, &rdb.AdvancedError{
+		Type:    errorType,
+		Message: message,
+	})
+}
+
+func emitConflict(w http.ResponseWriter, err error) {
+	emitError(w, http.StatusConflict, "Raven.Client.Exceptions.ConcurrencyException", err.Error())
+}
+
+func emitNotImplemented(w http.ResponseWriter, r *http.Request) {
+	emitError(w, http.StatusNotImplemented, "rdbtest.NotImplementedException", fmt.Sprintf("%s %s is not implemented in rdbtest", r.Method, r.URL.Path))
+}
@@ -0,0 +1,203 @@
+package rdbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb"
+)
+
+type testModel struct {
+	rdb.ModelTracking
+
+	ID   string
+	Name string
+	Age  int64
+	Ref  string
+}
+
+func (model *testModel) Collection() string {
+	return "TestModels"
+}
+
+func TestPutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	foo := &testModel{ID: "tests/1", Name: "foo"}
+	require.NoError(t, collection.Put(ctx, foo))
+	require.NotEmpty(t, foo.ChangeVector())
+
+	other := new(testModel)
+	require.NoError(t, collection.Get(ctx, "TESTS/1", &other))
+	require.Equal(t, other.ID, "tests/1")
+	require.Equal(t, other.Name, "foo")
+	require.Equal(t, other.ChangeVector(), foo.ChangeVector())
+
+	require.NoError(t, collection.Delete(ctx, other))
+
+	require.True(t, errors.Is(collection.Get(ctx, "tests/1", &other), rdb.ErrNoSuchEntity))
+}
+
+func TestConcurrentTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	foo := &testModel{ID: "tests/1", Name: "foo"}
+	require.NoError(t, collection.Put(ctx, foo))
+
+	var first, second *testModel
+	require.NoError(t, collection.Get(ctx, "tests/1", &first))
+	require.NoError(t, collection.Get(ctx, "tests/1", &second))
+
+	first.Name = "first"
+	require.NoError(t, collection.Put(ctx, first))
+
+	second.Name = "second"
+	require.True(t, errors.Is(collection.Put(ctx, second), rdb.ErrConcurrentTransaction))
+}
+
+func TestBatchRollback(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	foo := &testModel{ID: "tests/1", Name: "foo"}
+	require.NoError(t, collection.Put(ctx, foo))
+	var stale *testModel
+	require.NoError(t, collection.Get(ctx, "tests/1", &stale))
+	foo.Name = "updated"
+	require.NoError(t, collection.Put(ctx, foo))
+
+	ctx, sess := db.NewSession(ctx)
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/2", Name: "bar"}))
+	stale.Name = "stale"
+	require.NoError(t, collection.Put(ctx, stale))
+	require.True(t, errors.Is(sess.SaveChanges(ctx), rdb.ErrConcurrentTransaction))
+
+	var bar *testModel
+	require.True(t, errors.Is(collection.Get(context.Background(), "tests/2", &bar), rdb.ErrNoSuchEntity))
+}
+
+func TestGeneratedIDs(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	foo := &testModel{ID: "tests|", Name: "foo"}
+	require.NoError(t, collection.Put(ctx, foo))
+	require.Equal(t, foo.ID, "tests/1")
+
+	bar := &testModel{ID: "tests|", Name: "bar"}
+	require.NoError(t, collection.Put(ctx, bar))
+	require.Equal(t, bar.ID, "tests/2")
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/1", Name: "foo", Age: 30}))
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/2", Name: "bar", Age: 20}))
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/3", Name: "baz", Age: 10}))
+
+	var models []*testModel
+	require.NoError(t, db.Collection(new(testModel)).OrderBy("Age").GetAll(ctx, &models))
+	require.Len(t, models, 3)
+	require.Equal(t, models[0].ID, "tests/3")
+	require.Equal(t, models[2].ID, "tests/1")
+
+	require.NoError(t, db.Collection(new(testModel)).Filter("Name", "bar").GetAll(ctx, &models))
+	require.Len(t, models, 1)
+	require.Equal(t, models[0].ID, "tests/2")
+
+	q := db.Collection(new(testModel)).OrderBy("-Age").Limit(2)
+	require.NoError(t, q.GetAll(ctx, &models))
+	require.Len(t, models, 2)
+	require.Equal(t, models[0].ID, "tests/1")
+	require.EqualValues(t, q.Stats().TotalResults, 3)
+
+	n, err := db.Collection(new(testModel)).FilterIn("Age", 10, 30).Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, n, 2)
+}
+
+func TestIncludes(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/1", Name: "foo", Ref: "tests/2"}))
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/2", Name: "bar"}))
+
+	ctx, sess := db.NewSession(ctx)
+	var foo *testModel
+	require.NoError(t, collection.Get(ctx, "tests/1", &foo, rdb.Include("Ref")))
+
+	var bar *testModel
+	require.NoError(t, sess.Load("tests/2", &bar))
+	require.Equal(t, bar.Name, "bar")
+
+	ctx, sess = db.NewSession(context.Background())
+	var models []*testModel
+	require.NoError(t, db.Collection(new(testModel)).Filter("Name", "foo").GetAll(ctx, &models, rdb.Include("Ref")))
+	require.NoError(t, sess.Load("tests/2", &bar))
+	require.Equal(t, bar.Name, "bar")
+}
+
+func TestCounters(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/1"}))
+
+	ctx, sess := db.NewSession(ctx)
+	require.NoError(t, sess.Counter("tests/1", "views").Increment(ctx, 3))
+	require.NoError(t, sess.Counter("tests/1", "views").Decrement(ctx, 1))
+	require.True(t, errors.Is(sess.Counter("tests/2", "views").Increment(ctx, 1), rdb.ErrNoSuchEntity))
+
+	var foo *testModel
+	require.NoError(t, collection.Get(ctx, "tests/1", &foo, rdb.IncludeAllCounters()))
+	require.EqualValues(t, sess.Counter("tests/1", "views").Value(), 2)
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/1", Name: "foo"}))
+
+	it, err := collection.Stream(ctx)
+	require.NoError(t, err)
+	var model *testModel
+	require.NoError(t, it.Next(&model))
+	require.Equal(t, model.ID, "tests/1")
+	require.Equal(t, it.Next(&model), rdb.ErrDone)
+}
+
+func TestDeleteEverything(t *testing.T) {
+	ctx := context.Background()
+	db := Open(t, "test-db")
+	collection := db.Collection(new(testModel))
+
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/1", Name: "foo"}))
+	require.NoError(t, collection.Put(ctx, &testModel{ID: "tests/2", Name: "bar"}))
+
+	ids, err := collection.GetAllIDs(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, ids, []string{"tests/1", "tests/2"})
+
+	require.NoError(t, collection.DeleteEverything(ctx))
+
+	ids, err = collection.GetAllIDs(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
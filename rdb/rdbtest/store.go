@@ -0,0 +1,234 @@
+package rdbtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+type database struct {
+	name       string
+	documents  map[string]*document
+	counters   map[string]map[string]int64
+	identities map[string]int64
+	indexes    map[string]*api.Index
+	revisions  *api.Revisions
+}
+
+func newDatabase(name string) *database {
+	return &database{
+		name:       name,
+		documents:  make(map[string]*document),
+		counters:   make(map[string]map[string]int64),
+		identities: make(map[string]int64),
+		indexes:    make(map[string]*api.Index),
+	}
+}
+
+type document struct {
+	id           string
+	collection   string
+	changeVector string
+	lastModified time.Time
+	data         map[string]interface{}
+
+	// Additional metadata sent by the client, like the expiration.
+	metadata map[string]interface{}
+}
+
+// result returns the document as the server sends it to the clients.
+func (doc *document) result(db *database) map[string]interface{} {
+	result := make(map[string]interface{}, len(doc.data)+1)
+	for k, v := range doc.data {
+		result[k] = v
+	}
+	result["@metadata"] = doc.resultMetadata(db)
+	return result
+}
+
+func (doc *document) resultMetadata(db *database) map[string]interface{} {
+	md := map[string]interface{}{
+		"@id":            doc.id,
+		"@collection":    doc.collection,
+		"@change-vector": doc.changeVector,
+		"@last-modified": doc.lastModified.Format(api.DateTimeFormat),
+	}
+	for k, v := range doc.metadata {
+		md[k] = v
+	}
+	if counters := db.counters[strings.ToLower(doc.id)]; len(counters) > 0 {
+		names := make([]interface{}, 0, len(counters))
+		for name := range counters {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i].(string) < names[j].(string) })
+		md["@counters"] = names
+		md["@flags"] = "HasCounters"
+	}
+	return md
+}
+
+// conflictError is returned when the change vector sent by the client does not
+// match the one of the stored document.
+type conflictError struct {
+	id string
+}
+
+func (err conflictError) Error() string {
+	return fmt.Sprintf("optimistic concurrency violation, document %s has a different change vector", err.id)
+}
+
+func (db *database) get(id string) *document {
+	return db.documents[strings.ToLower(id)]
+}
+
+// put stores the document and returns it. The etag generates new change vectors
+// and IDs when needed.
+func (db *database) put(id string, changeVector *string, body map[string]interface{}, etag int64) (*document, error) {
+	current := db.get(id)
+	if changeVector != nil && *changeVector != "" && (current == nil || current.changeVector != *changeVector) {
+		return nil, conflictError{id}
+	}
+
+	doc := &document{
+		changeVector: fmt.Sprintf("A:%d-rdbtest", etag),
+		lastModified: time.Now().UTC(),
+		data:         make(map[string]interface{}),
+		metadata:     make(map[string]interface{}),
+	}
+	for k, v := range body {
+		if k == "@metadata" {
+			continue
+		}
+		doc.data[k] = v
+	}
+	md, _ := body["@metadata"].(map[string]interface{})
+	for k, v := range md {
+		switch k {
+		case "@collection":
+			doc.collection, _ = v.(string)
+		case "@id", "@change-vector", "@last-modified", "@counters", "@flags":
+		default:
+			doc.metadata[k] = v
+		}
+	}
+	if doc.collection == "" {
+		doc.collection = "@empty"
+	}
+	doc.id = db.generateID(id, doc.collection, etag)
+
+	db.documents[strings.ToLower(doc.id)] = doc
+	return doc, nil
+}
+
+// generateID returns the ID of a new document following the conventions of the
+// server when it is empty or ends with a separator.
+func (db *database) generateID(id, collection string, etag int64) string {
+	switch {
+	case id == "":
+		return fmt.Sprintf("%s/%019d-A", strings.ToLower(collection), etag)
+	case strings.HasSuffix(id, "/"):
+		return fmt.Sprintf("%s%019d-A", id, etag)
+	case strings.HasSuffix(id, "|"):
+		db.identities[id]++
+		return fmt.Sprintf("%s/%d", strings.TrimSuffix(id, "|"), db.identities[id])
+	}
+	return id
+}
+
+func (db *database) delete(id string, changeVector *string) (bool, error) {
+	current := db.get(id)
+	if changeVector != nil && *changeVector != "" && (current == nil || current.changeVector != *changeVector) {
+		return false, conflictError{id}
+	}
+	if current == nil {
+		return false, nil
+	}
+
+	delete(db.documents, strings.ToLower(id))
+	delete(db.counters, strings.ToLower(id))
+	return true, nil
+}
+
+func (db *database) deletePrefix(prefix string) {
+	prefix = strings.ToLower(prefix)
+	for id := range db.documents {
+		if strings.HasPrefix(id, prefix) {
+			delete(db.documents, id)
+			delete(db.counters, id)
+		}
+	}
+}
+
+// includes returns the documents referenced by the fields of the results.
+func (db *database) includes(results []map[string]interface{}, fields []string) map[string]interface{} {
+	includes := make(map[string]interface{})
+	for _, result := range results {
+		for _, field := range fields {
+			for _, value := range fieldValues(result, field) {
+				id, ok := value.(string)
+				if !ok || id == "" {
+					continue
+				}
+				if doc := db.get(id); doc != nil {
+					includes[id] = doc.result(db)
+				} else {
+					includes[id] = nil
+				}
+			}
+		}
+	}
+	return includes
+}
+
+// snapshot copies the state that batches can modify to restore it if one of
+// the commands fails.
+func (db *database) snapshot() *database {
+	cloned := *db
+	cloned.documents = make(map[string]*document, len(db.documents))
+	for k, v := range db.documents {
+		cloned.documents[k] = v
+	}
+	cloned.counters = make(map[string]map[string]int64, len(db.counters))
+	for k, v := range db.counters {
+		cloned.counters[k] = v
+	}
+	cloned.identities = make(map[string]int64, len(db.identities))
+	for k, v := range db.identities {
+		cloned.identities[k] = v
+	}
+	return &cloned
+}
+
+func (db *database) restore(snapshot *database) {
+	db.documents = snapshot.documents
+	db.counters = snapshot.counters
+	db.identities = snapshot.identities
+}
+
+// query runs the query and returns all the matching results before applying
+// the pagination.
+func (db *database) query(q *rqlQuery) []map[string]interface{} {
+	var results []map[string]interface{}
+	for _, doc := range db.documents {
+		if !strings.EqualFold(doc.collection, q.collection) {
+			continue
+		}
+		result := doc.result(db)
+		if q.where != nil && !q.where.match(result) {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	// Start with a stable order before applying the requested one.
+	sort.Slice(results, func(i, j int) bool {
+		return documentID(results[i]) < documentID(results[j])
+	})
+	sortDocuments(results, q.orders)
+
+	return results
+}
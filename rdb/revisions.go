@@ -0,0 +1,211 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// RevisionMetadata describes a revision of a document without its content.
+type RevisionMetadata struct {
+	ChangeVector string
+	LastModified time.Time
+
+	// The revision was created when the document was deleted and has no content.
+	Deleted bool
+}
+
+// GetRevisions reads a page of the revisions of the document in dest, that should
+// be a pointer to a slice of models. The most recent revisions are returned first.
+// Revisions of deleted documents are skipped, use GetRevisionsMetadata to know
+// when they happened.
+func (collection *Collection) GetRevisions(ctx context.Context, id string, dest interface{}, start, pageSize int64) error {
+	rt := reflect.TypeOf(dest)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice || rt.Elem().Elem().Kind() != reflect.Ptr || rt.Elem().Elem().Elem().Kind() != reflect.Struct {
+		return errors.Errorf("dest should be a pointer to a slice of models: %T", dest)
+	}
+
+	params := map[string]interface{}{
+		"id":       id,
+		"start":    strconv.FormatInt(start, 10),
+		"pageSize": strconv.FormatInt(pageSize, 10),
+	}
+	results, err := collection.getRevisions(ctx, params)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	slice := reflect.MakeSlice(rt.Elem(), 0, len(results))
+	for _, result := range results {
+		if isDeleteRevision(result) {
+			continue
+		}
+
+		item := reflect.New(rt.Elem().Elem())
+		model, err := createModel(item.Interface(), result)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if model != nil && !collection.checkEnforcers(model) {
+			return newNoSuchEntityError("enforced id %q", id)
+		}
+		slice = reflect.Append(slice, item.Elem())
+	}
+	reflect.ValueOf(dest).Elem().Set(slice)
+
+	return nil
+}
+
+// GetRevisionsMetadata returns a page of the revisions of the document without their
+// content. The most recent revisions are returned first.
+func (collection *Collection) GetRevisionsMetadata(ctx context.Context, id string, start, pageSize int64) ([]RevisionMetadata, error) {
+	params := map[string]interface{}{
+		"id":           id,
+		"start":        strconv.FormatInt(start, 10),
+		"pageSize":     strconv.FormatInt(pageSize, 10),
+		"metadataOnly": "true",
+	}
+	results, err := collection.getRevisions(ctx, params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	revisions := make([]RevisionMetadata, len(results))
+	for i, result := range results {
+		revisions[i] = RevisionMetadata{
+			ChangeVector: result.Metadata("@change-vector"),
+			Deleted:      isDeleteRevision(result),
+		}
+		if lastModified := result.Metadata("@last-modified"); lastModified != "" {
+			revisions[i].LastModified, err = time.Parse(api.DateTimeFormat, lastModified)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+	}
+
+	return revisions, nil
+}
+
+// GetRevisionAt reads in dest the content the document had at the specified time.
+// If the document did not exist at that time it returns ErrNoSuchEntity.
+func (collection *Collection) GetRevisionAt(ctx context.Context, id string, t time.Time, dest interface{}) error {
+	if err := checkSingleModel(dest); err != nil {
+		return errors.Trace(err)
+	}
+
+	params := map[string]interface{}{
+		"id":     id,
+		"before": t.In(time.UTC).Format(api.DateTimeFormat),
+	}
+	results, err := collection.getRevisions(ctx, params)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(results) == 0 || isDeleteRevision(results[0]) {
+		return newNoSuchEntityError("revision of id %q at %s", id, t)
+	}
+
+	model, err := createModel(dest, results[0])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if model != nil && !collection.checkEnforcers(model) {
+		if err := json.Unmarshal([]byte("null"), dest); err != nil {
+			return errors.Trace(err)
+		}
+		return newNoSuchEntityError("enforced id %q", id)
+	}
+
+	return nil
+}
+
+type revertRevisionAction struct {
+	id       string
+	document api.Result
+}
+
+func (action *revertRevisionAction) batchCommand() (api.BatchCommand, error) {
+	return &api.PutCommand{
+		ID:       action.id,
+		Document: action.document,
+		Type:     "PUT",
+	}, nil
+}
+
+// RevertRevision stores again the content of the revision of the document, as
+// a new version on top of the current one. The change vector can be obtained
+// with GetRevisionsMetadata. If there is a session in the context it will be
+// stored in the next SaveChanges call.
+func (collection *Collection) RevertRevision(ctx context.Context, id, changeVector string) error {
+	params := map[string]interface{}{
+		"changeVector": changeVector,
+	}
+	results, err := collection.getRevisions(ctx, params)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(results) == 0 || !strings.EqualFold(results[0].Metadata("@id"), id) {
+		return newNoSuchEntityError("revision %q of id %q", changeVector, id)
+	}
+	if isDeleteRevision(results[0]) {
+		return errors.Errorf("cannot revert to the deletion of id %q, delete the document instead", id)
+	}
+
+	// Keep only the collection of the revision to store it as a normal document.
+	document := results[0]
+	document["@metadata"] = map[string]interface{}{
+		"@collection": document.Metadata("@collection"),
+	}
+	action := &revertRevisionAction{
+		id:       id,
+		document: document,
+	}
+
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		ctx, sess := collection.db.NewSession(ctx)
+		sess.actions = append(sess.actions, action)
+		return errors.Trace(sess.SaveChanges(ctx))
+	}
+
+	sess.actions = append(sess.actions, action)
+	return nil
+}
+
+func (collection *Collection) getRevisions(ctx context.Context, params map[string]interface{}) ([]api.Result, error) {
+	r, err := collection.conn.buildGET(collection.conn.endpoint("revisions"), params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := collection.conn.sendRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		results := new(api.Results)
+		if err := json.NewDecoder(resp.Body).Decode(results); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return results.Results, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+func isDeleteRevision(result api.Result) bool {
+	return strings.Contains(result.Metadata("@flags"), "DeleteRevision")
+}
@@ -0,0 +1,145 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+const revisionsTestResults = `{
+	"Results": [
+		{
+			"DisplayName": "Foo2",
+			"@metadata": {
+				"@id": "foo-collections/1",
+				"@collection": "FooCollectionModels",
+				"@change-vector": "A:2",
+				"@last-modified": "2020-01-02T03:04:05.0000000Z",
+				"@flags": "HasRevisions, Revision"
+			}
+		},
+		{
+			"@metadata": {
+				"@id": "foo-collections/1",
+				"@change-vector": "A:1",
+				"@last-modified": "2020-01-01T03:04:05.0000000Z",
+				"@flags": "HasRevisions, DeleteRevision"
+			}
+		}
+	]
+}`
+
+func newRevisionsTestCollection(t *testing.T, handler http.HandlerFunc) *Collection {
+	return newFakeDatabase(t, handler).Collection(new(FooCollectionModel))
+}
+
+func TestGetRevisions(t *testing.T) {
+	var query map[string][]string
+	collection := newRevisionsTestCollection(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(revisionsTestResults))
+	})
+
+	var revisions []*FooCollectionModel
+	require.NoError(t, collection.GetRevisions(context.Background(), "foo-collections/1", &revisions, 0, 10))
+	require.Equal(t, query["id"], []string{"foo-collections/1"})
+	require.Equal(t, query["pageSize"], []string{"10"})
+
+	require.Len(t, revisions, 1)
+	require.Equal(t, revisions[0].ID, "foo-collections/1")
+	require.Equal(t, revisions[0].DisplayName, "Foo2")
+	require.Equal(t, revisions[0].ChangeVector(), "A:2")
+
+	metadata, err := collection.GetRevisionsMetadata(context.Background(), "foo-collections/1", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, query["metadataOnly"], []string{"true"})
+	require.Equal(t, metadata, []RevisionMetadata{
+		{
+			ChangeVector: "A:2",
+			LastModified: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			ChangeVector: "A:1",
+			LastModified: time.Date(2020, time.January, 1, 3, 4, 5, 0, time.UTC),
+			Deleted:      true,
+		},
+	})
+}
+
+func TestRevertRevision(t *testing.T) {
+	var batch map[string]interface{}
+	collection := newRevisionsTestCollection(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/databases/foo-db/revisions":
+			if r.URL.Query().Get("changeVector") == "A:1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(revisionsTestResults))
+		case "/databases/foo-db/bulk_docs":
+			_ = json.NewDecoder(r.Body).Decode(&batch)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"Results": [{}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	require.NoError(t, collection.RevertRevision(context.Background(), "foo-collections/1", "A:2"))
+	require.Equal(t, batch["Commands"], []interface{}{
+		map[string]interface{}{
+			"Id":           "foo-collections/1",
+			"ChangeVector": nil,
+			"Type":         "PUT",
+			"Document": map[string]interface{}{
+				"DisplayName": "Foo2",
+				"@metadata": map[string]interface{}{
+					"@collection": "FooCollectionModels",
+				},
+			},
+		},
+	})
+
+	err := collection.RevertRevision(context.Background(), "foo-collections/1", "A:1")
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+}
+
+func TestRevisionsHistory(t *testing.T) {
+	ctx := context.Background()
+	db := initCollectionTestbed(t)
+	collection := db.Collection(new(FooCollectionModel))
+	require.NoError(t, collection.ConfigureRevisions(ctx, &api.RevisionConfig{MinimumRevisionsToKeep: 5}))
+
+	foo := &FooCollectionModel{
+		ID:          "foo-collections/revisions",
+		DisplayName: "Foo1",
+	}
+	require.NoError(t, collection.Put(ctx, foo))
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	foo.DisplayName = "Foo2"
+	require.NoError(t, collection.Put(ctx, foo))
+
+	var revisions []*FooCollectionModel
+	require.NoError(t, collection.GetRevisions(ctx, foo.ID, &revisions, 0, 10))
+	require.Len(t, revisions, 2)
+	require.Equal(t, revisions[0].DisplayName, "Foo2")
+	require.Equal(t, revisions[1].DisplayName, "Foo1")
+
+	var old *FooCollectionModel
+	require.NoError(t, collection.GetRevisionAt(ctx, foo.ID, before, &old))
+	require.Equal(t, old.DisplayName, "Foo1")
+
+	require.NoError(t, collection.RevertRevision(ctx, foo.ID, revisions[1].ChangeVector()))
+
+	var reverted *FooCollectionModel
+	require.NoError(t, collection.Get(ctx, foo.ID, &reverted))
+	require.Equal(t, reverted.DisplayName, "Foo1")
+}
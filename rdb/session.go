@@ -1,9 +1,15 @@
 package rdb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/altipla-consulting/errors"
 
@@ -17,8 +23,34 @@ type Session struct {
 	// Pending actions that will be performed in a single batch
 	actions []sessionAction
 
-	includes map[string]api.Result
-	counters map[string]int64
+	includes   map[string]api.Result
+	counters   map[string]int64
+	timeSeries map[string][]*timeSeriesRange
+
+	clusterWide bool
+
+	// Identity map of the models loaded in the session, by lowercase ID.
+	documents map[string]*trackedModel
+}
+
+type trackedModel struct {
+	model Model
+
+	// Serialized document when it was loaded or last saved.
+	snapshot []byte
+}
+
+type SessionOption func(sess *Session)
+
+// WithClusterWideTransaction saves all the changes of the session atomically in
+// the whole cluster. It is required to store compare exchange values with the
+// documents. Change vectors are not checked in this mode, the compare exchange
+// values should be used to detect concurrent changes instead. Attachments cannot
+// be stored in cluster-wide transactions.
+func WithClusterWideTransaction() SessionOption {
+	return func(sess *Session) {
+		sess.clusterWide = true
+	}
 }
 
 type sessionAction interface {
@@ -88,13 +120,56 @@ func (action *deleteIDAction) batchCommand() (api.BatchCommand, error) {
 	}, nil
 }
 
+// SaveChanges sends all the pending actions of the session to the server in a
+// single batch. Models loaded in the session that have been modified are stored
+// too, even without calling Put, using their change vectors to detect concurrent
+// modifications.
 func (sess *Session) SaveChanges(ctx context.Context) error {
+	if err := sess.storeModified(); err != nil {
+		return errors.Trace(err)
+	}
 	if len(sess.actions) == 0 {
 		return nil
 	}
 
+	if err := sess.sendActions(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, action := range sess.actions {
+		switch action := action.(type) {
+		case *storeModelAction:
+			if err := sess.trackModel(action.model); err != nil {
+				return errors.Trace(err)
+			}
+		case *deleteModelAction:
+			id, err := getModelID(action.model)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			delete(sess.documents, strings.ToLower(id))
+		case *deleteIDAction:
+			delete(sess.documents, strings.ToLower(action.id))
+		case *deletePrefixAction:
+			for id := range sess.documents {
+				if strings.HasPrefix(id, strings.ToLower(action.prefix)) {
+					delete(sess.documents, id)
+				}
+			}
+		case *revertRevisionAction:
+			delete(sess.documents, strings.ToLower(action.id))
+		case *patchAction:
+			delete(sess.documents, strings.ToLower(action.id))
+		}
+	}
+	sess.actions = nil
+
+	return nil
+}
+
+func (sess *Session) sendActions(ctx context.Context) error {
 	// Optimized single action operations.
-	if len(sess.actions) == 1 {
+	if len(sess.actions) == 1 && !sess.clusterWide {
 		switch action := sess.actions[0].(type) {
 		case *storeModelAction:
 			id, err := getModelID(action.model)
@@ -183,24 +258,99 @@ func (sess *Session) SaveChanges(ctx context.Context) error {
 			default:
 				return NewUnexpectedStatusError(r, resp)
 			}
+
+		case *patchAction:
+			params := map[string]string{"id": action.id}
+			r, err := sess.conn.buildPATCH(sess.conn.endpoint("docs"), params, &api.DocumentPatch{Patch: action.script})
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if action.changeVector != "" {
+				r.Header.Set("If-Match", action.changeVector)
+			}
+			resp, err := sess.conn.sendRequest(ctx, r)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			defer resp.Body.Close()
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				return nil
+			case http.StatusNotFound:
+				if action.ignoreMissing {
+					return nil
+				}
+				return newNoSuchEntityError("id %q", action.id)
+			case http.StatusConflict:
+				return errors.Trace(ErrConcurrentTransaction)
+			default:
+				return NewUnexpectedStatusError(r, resp)
+			}
+
+		case *putAttachmentAction:
+			return errors.Trace(action.send(ctx, sess.conn))
+
+		case *deleteAttachmentAction:
+			return errors.Trace(action.send(ctx, sess.conn))
 		}
 	}
 
 	// Batch commands sending multiple operations in one go.
 	batch := new(api.BulkCommands)
+	var streams []io.Reader
 	for _, action := range sess.actions {
 		cmd, err := action.batchCommand()
 		if err != nil {
 			return errors.Trace(err)
 		}
 		batch.Commands = append(batch.Commands, cmd)
+
+		if put, ok := action.(*putAttachmentAction); ok {
+			streams = append(streams, put.content)
+		}
+	}
+	if sess.clusterWide {
+		if len(streams) > 0 {
+			return errors.Errorf("cannot store attachments in a cluster-wide transaction")
+		}
+		batch.TransactionMode = api.TransactionModeClusterWide
+		for _, cmd := range batch.Commands {
+			switch cmd := cmd.(type) {
+			case *api.PutCommand:
+				cmd.ChangeVector = nil
+			case *api.DeleteCommand:
+				cmd.ChangeVector = nil
+			case *api.PatchCommand:
+				return errors.Errorf("cannot patch documents in a cluster-wide transaction")
+			}
+		}
 	}
 
-	r, err := sess.conn.buildPOST(sess.conn.endpoint("bulk_docs"), nil, batch)
-	if err != nil {
-		return errors.Trace(err)
+	var r *http.Request
+	var resp *http.Response
+	var err error
+	if len(streams) == 0 {
+		r, err = sess.conn.buildPOST(sess.conn.endpoint("bulk_docs"), nil, batch)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		resp, err = sess.conn.sendRequest(ctx, r)
+	} else {
+		// Attachments are streamed in a multipart body after the commands.
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		w := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(writeBatchMultipart(w, batch, streams))
+		}()
+
+		r, err = sess.conn.buildStream(http.MethodPost, sess.conn.endpoint("bulk_docs"), nil, "multipart/mixed; boundary="+w.Boundary(), pr)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		resp, err = sess.conn.sendStreamRequest(ctx, r)
 	}
-	resp, err := sess.conn.sendRequest(ctx, r)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -227,17 +377,173 @@ func (sess *Session) SaveChanges(ctx context.Context) error {
 		return NewUnexpectedStatusError(r, resp)
 	}
 
-	sess.actions = nil
 	return nil
 }
 
+// Load reads a model previously loaded in the session or included by other
+// operation. Loading the same ID multiple times returns the same model.
 func (sess *Session) Load(id string, dest interface{}) error {
+	if err := checkSingleModel(dest); err != nil {
+		return errors.Trace(err)
+	}
+
+	if tracked, ok := sess.documents[strings.ToLower(id)]; ok {
+		return errors.Trace(setTrackedModel(dest, tracked.model))
+	}
+
 	result, ok := sess.includes[id]
 	if !ok {
 		return newNoSuchEntityError("included id %q", id)
 	}
-	_, err := createModel(dest, result)
-	return errors.Trace(err)
+	if _, err := createModel(dest, result); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(sess.track(dest))
+}
+
+// track registers the model loaded in dest, a pointer to a model, in the identity
+// map of the session. If a model with the same ID was already loaded, dest will be
+// replaced with it to always work with the same instance.
+func (sess *Session) track(dest interface{}) error {
+	if sess == nil {
+		return nil
+	}
+
+	model, ok := reflect.ValueOf(dest).Elem().Interface().(Model)
+	if !ok || !isTrackable(model) {
+		return nil
+	}
+	id, err := getModelID(model)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if id == "" {
+		return nil
+	}
+
+	if tracked, ok := sess.documents[strings.ToLower(id)]; ok {
+		return errors.Trace(setTrackedModel(dest, tracked.model))
+	}
+	return errors.Trace(sess.trackModel(model))
+}
+
+// trackModel registers the model in the identity map, taking a new snapshot of
+// its contents to detect future modifications.
+func (sess *Session) trackModel(model Model) error {
+	if !isTrackable(model) {
+		return nil
+	}
+	id, err := getModelID(model)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if id == "" {
+		return nil
+	}
+
+	snapshot, err := snapshotModel(model)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if sess.documents == nil {
+		sess.documents = make(map[string]*trackedModel)
+	}
+	sess.documents[strings.ToLower(id)] = &trackedModel{
+		model:    model,
+		snapshot: snapshot,
+	}
+
+	return nil
+}
+
+// storeModified queues a store action for each loaded model that has changed since
+// it was loaded and it is not already queued. Documents deleted, patched or reverted
+// by other queued actions are skipped too, storing them afterwards would undo
+// those changes.
+func (sess *Session) storeModified() error {
+	pending := make(map[Model]bool)
+	excluded := make(map[string]bool)
+	var prefixes []string
+	for _, action := range sess.actions {
+		switch action := action.(type) {
+		case *storeModelAction:
+			pending[action.model] = true
+		case *deleteModelAction:
+			pending[action.model] = true
+		case *deleteIDAction:
+			excluded[strings.ToLower(action.id)] = true
+		case *deletePrefixAction:
+			prefixes = append(prefixes, strings.ToLower(action.prefix))
+		case *patchAction:
+			excluded[strings.ToLower(action.id)] = true
+		case *revertRevisionAction:
+			excluded[strings.ToLower(action.id)] = true
+		}
+	}
+
+	// Sort the IDs to always send the changes in the same order.
+	ids := make([]string, 0, len(sess.documents))
+	for id := range sess.documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		tracked := sess.documents[id]
+		if pending[tracked.model] || excluded[id] || hasAnyPrefix(id, prefixes) {
+			continue
+		}
+		snapshot, err := snapshotModel(tracked.model)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if !bytes.Equal(snapshot, tracked.snapshot) {
+			sess.actions = append(sess.actions, &storeModelAction{tracked.model})
+		}
+	}
+
+	return nil
+}
+
+func hasAnyPrefix(id string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrackable(model Model) bool {
+	rv := reflect.ValueOf(model)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	if _, ok := rv.Elem().Type().FieldByName("ID"); !ok {
+		return false
+	}
+	return model.Tracking() != nil
+}
+
+func snapshotModel(model Model) ([]byte, error) {
+	serialized, err := serializeModel(model)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	snapshot, err := json.Marshal(serialized)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return snapshot, nil
+}
+
+func setTrackedModel(dest interface{}, model Model) error {
+	rv := reflect.ValueOf(dest).Elem()
+	if reflect.TypeOf(model) != rv.Type() {
+		return errors.Errorf("model already loaded in the session with a different type: %T", model)
+	}
+	rv.Set(reflect.ValueOf(model))
+	return nil
 }
 
 func (sess *Session) Counter(docID string, name string) *Counter {
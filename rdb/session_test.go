@@ -2,9 +2,13 @@ package rdb
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
 )
 
 func TestLoadMultipleTimesSameEntityDoesNotFail(t *testing.T) {
@@ -39,3 +43,111 @@ func TestLoadMultipleTimesSameEntityDoesNotFail(t *testing.T) {
 	require.Equal(t, repeated.ID, "foo-collections/4")
 	require.Equal(t, repeated.DisplayName, "Foo-Dest")
 }
+
+func newIncludesSession(t *testing.T, handler http.HandlerFunc) (*Database, *Session) {
+	db := newFakeDatabase(t, handler)
+	_, sess := db.NewSession(context.Background())
+	sess.mergeIncludes(map[string]api.Result{
+		"foo-queries/1": {
+			"DisplayName": "Foo1",
+			"@metadata": map[string]interface{}{
+				"@id":            "foo-queries/1",
+				"@collection":    "FooQueryModels",
+				"@change-vector": "A:1",
+			},
+		},
+	})
+	return db, sess
+}
+
+func TestSessionIdentityMap(t *testing.T) {
+	_, sess := newIncludesSession(t, nil)
+
+	var first, second *FooQueryModel
+	require.NoError(t, sess.Load("foo-queries/1", &first))
+	require.NoError(t, sess.Load("FOO-QUERIES/1", &second))
+	require.True(t, first == second)
+
+	var other *FooQueryNumericModel
+	require.Error(t, sess.Load("foo-queries/1", &other))
+}
+
+func TestSessionDirtyTracking(t *testing.T) {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	_, sess := newIncludesSession(t, func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]interface{})
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id": "foo-queries/1", "ChangeVector": "A:2"}`))
+	})
+	ctx := context.WithValue(context.Background(), keySession, sess)
+
+	var foo *FooQueryModel
+	require.NoError(t, sess.Load("foo-queries/1", &foo))
+
+	require.NoError(t, sess.SaveChanges(ctx))
+	require.Empty(t, requests)
+
+	foo.DisplayName = "Foo1 modified"
+	require.NoError(t, sess.SaveChanges(ctx))
+	require.Len(t, requests, 1)
+	require.Equal(t, requests[0].Method, http.MethodPut)
+	require.Equal(t, requests[0].URL.Query().Get("id"), "foo-queries/1")
+	require.Equal(t, requests[0].Header.Get("If-Match"), "A:1")
+	require.Equal(t, bodies[0]["DisplayName"], "Foo1 modified")
+	require.Equal(t, foo.ChangeVector(), "A:2")
+
+	// The new snapshot should not send the model again.
+	require.NoError(t, sess.SaveChanges(ctx))
+	require.Len(t, requests, 1)
+}
+
+func TestSessionDeleteModifiedModels(t *testing.T) {
+	var requests []*http.Request
+	db, sess := newIncludesSession(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.Method {
+		case http.MethodPost:
+			_, _ = w.Write([]byte(`{"Results": [{"@metadata": {"@id": "foo-queries/1", "@change-vector": "A:1"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	ctx := context.WithValue(context.Background(), keySession, sess)
+
+	var foo *FooQueryModel
+	require.NoError(t, sess.Load("foo-queries/1", &foo))
+	foo.DisplayName = "Foo1 modified"
+
+	require.NoError(t, db.Collection(new(FooQueryModel)).DeleteEverything(ctx))
+	require.NoError(t, sess.SaveChanges(ctx))
+
+	require.Len(t, requests, 2)
+	require.Equal(t, requests[1].Method, http.MethodDelete)
+	require.Equal(t, requests[1].URL.Query().Get("id"), "foo-queries/1")
+	require.NotContains(t, sess.documents, "foo-queries/1")
+}
+
+func TestSessionSaveModifiedModels(t *testing.T) {
+	db := initCollectionTestbed(t)
+	collection := db.Collection(new(FooCollectionModel))
+
+	require.NoError(t, collection.Put(context.Background(), &FooCollectionModel{ID: "foo-collections/3", DisplayName: "foo"}))
+
+	ctx, sess := db.NewSession(context.Background())
+	var first, second *FooCollectionModel
+	require.NoError(t, collection.Get(ctx, "foo-collections/3", &first))
+	require.NoError(t, collection.Get(ctx, "foo-collections/3", &second))
+	require.True(t, first == second)
+
+	first.DisplayName = "bar"
+	require.NoError(t, sess.SaveChanges(ctx))
+
+	var other *FooCollectionModel
+	require.NoError(t, collection.Get(context.Background(), "foo-collections/3", &other))
+	require.Equal(t, other.DisplayName, "bar")
+	require.Equal(t, other.ChangeVector(), first.ChangeVector())
+}
@@ -0,0 +1,210 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// StreamIterator reads the results of a streamed query one by one directly from
+// the response of the server. Memory usage is constant regardless of the number
+// of results, so it is the preferred way to process big collections in batch jobs.
+type StreamIterator struct {
+	body  io.ReadCloser
+	dec   *json.Decoder
+	stats QueryStats
+	done  bool
+}
+
+// Stream sends the query to the server and returns an iterator to read the results
+// as they arrive. Includes are not supported and streamed queries never wait for
+// stale indexes. Close the iterator if you stop reading before the end.
+func (q *Query) Stream(ctx context.Context) (*StreamIterator, error) {
+	it, err := q.stream(ctx, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return it, nil
+}
+
+func (q *Query) stream(ctx context.Context, params map[string]interface{}) (*StreamIterator, error) {
+	query := q.buildQuery()
+	query.WaitForNonStaleResults = false
+	query.WaitForNonStaleResultsTimeout = ""
+	it, err := openStream(ctx, q.conn, query, params)
+	if err != nil {
+		if errors.Is(err, errIndexNotFound) {
+			return nil, errors.Errorf("index not found: %s", q.index)
+		}
+		return nil, errors.Trace(err)
+	}
+	return it, nil
+}
+
+// Stream sends the query to the server and returns an iterator to read the results
+// as they arrive. Streamed queries never wait for stale indexes.
+func (q *DirectQuery) Stream(ctx context.Context) (*StreamIterator, error) {
+	query := q.buildQuery()
+	query.WaitForNonStaleResults = false
+	query.WaitForNonStaleResultsTimeout = ""
+	it, err := openStream(ctx, q.conn, query, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return it, nil
+}
+
+var errIndexNotFound = errors.New("index not found")
+
+func openStream(ctx context.Context, conn *connection, query *api.Query, params map[string]interface{}) (*StreamIterator, error) {
+	r, err := conn.buildPOST(conn.endpoint("streams/queries"), params, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := conn.sendStreamRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		it := newStreamIterator(resp.Body)
+		if err := it.readHeader(); err != nil {
+			_ = it.Close()
+			return nil, errors.Trace(err)
+		}
+		return it, nil
+	case http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, errors.Trace(errIndexNotFound)
+	default:
+		err := NewUnexpectedStatusError(r, resp)
+		_ = resp.Body.Close()
+		return nil, err
+	}
+}
+
+func newStreamIterator(body io.ReadCloser) *StreamIterator {
+	return &StreamIterator{
+		body: body,
+		dec:  json.NewDecoder(body),
+	}
+}
+
+// readHeader consumes the response until the beginning of the results array,
+// storing the stats the server sends before them.
+func (it *StreamIterator) readHeader() error {
+	if err := it.expectDelim('{'); err != nil {
+		return errors.Trace(err)
+	}
+
+	for it.dec.More() {
+		key, err := it.dec.Token()
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		switch key {
+		case "Results":
+			return errors.Trace(it.expectDelim('['))
+		case "TotalResults":
+			err = it.dec.Decode(&it.stats.TotalResults)
+			it.stats.ResultSize = it.stats.TotalResults
+		case "SkippedResults":
+			err = it.dec.Decode(&it.stats.SkippedResults)
+		case "DurationInMs":
+			err = it.dec.Decode(&it.stats.DurationInMs)
+		case "IndexName":
+			err = it.dec.Decode(&it.stats.IndexName)
+		case "IsStale":
+			err = it.dec.Decode(&it.stats.IsStale)
+		default:
+			var discard json.RawMessage
+			err = it.dec.Decode(&discard)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return errors.Errorf("stream response without results")
+}
+
+func (it *StreamIterator) expectDelim(delim json.Delim) error {
+	token, err := it.dec.Token()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if token != delim {
+		return errors.Errorf("unexpected token in stream response: %v, expected %v", token, delim)
+	}
+	return nil
+}
+
+// Stats returns the statistics the server sent before the results.
+func (it *StreamIterator) Stats() QueryStats {
+	return it.stats
+}
+
+// Next reads the next result into dest, which should be a pointer to a model
+// like in First. A new model is allocated in each call. When the iterator reaches
+// the end of the results it closes the response and returns ErrDone.
+func (it *StreamIterator) Next(dest interface{}) error {
+	if err := checkSingleModel(dest); err != nil {
+		return errors.Trace(err)
+	}
+
+	result, err := it.nextResult()
+	if err != nil {
+		return err
+	}
+
+	// Always allocate a new model to avoid mixing fields with the previous result.
+	rv := reflect.ValueOf(dest).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	if _, err := createModel(dest, result); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// nextResult reads the next result without converting it to a model. It returns
+// ErrDone after closing the response when there are no more results.
+func (it *StreamIterator) nextResult() (api.Result, error) {
+	if it.done {
+		return nil, ErrDone
+	}
+
+	if !it.dec.More() {
+		if err := it.expectDelim(']'); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := it.Close(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, ErrDone
+	}
+
+	var result api.Result
+	if err := it.dec.Decode(&result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// Close finishes the iteration and releases the connection. Do not use the
+// iterator after closing it.
+func (it *StreamIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	return errors.Trace(it.body.Close())
+}
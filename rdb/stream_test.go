@@ -0,0 +1,139 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// streamTestHandler streams n documents and records the requests and the IDs
+// of the batches of commands sent to the server.
+func streamTestHandler(n int, requests *[]string, batches *[][]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path+"?"+r.URL.RawQuery)
+		switch r.URL.Path {
+		case "/databases/foo-db/queries":
+			fmt.Fprintf(w, `{"Results": [], "TotalResults": %d}`, n)
+		case "/databases/foo-db/streams/queries":
+			fmt.Fprint(w, `{"Results": [`)
+			for i := 1; i <= n; i++ {
+				if i > 1 {
+					fmt.Fprint(w, ",")
+				}
+				fmt.Fprintf(w, `{"@metadata": {"@id": "foo-queries/%d"}}`, i)
+			}
+			fmt.Fprint(w, `]}`)
+		case "/databases/foo-db/bulk_docs":
+			var batch struct {
+				Commands []struct {
+					ID string `json:"Id"`
+				}
+			}
+			_ = json.NewDecoder(r.Body).Decode(&batch)
+			var ids []string
+			for _, cmd := range batch.Commands {
+				ids = append(ids, cmd.ID)
+			}
+			*batches = append(*batches, ids)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"Results": []}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func TestStreamIteratorDecode(t *testing.T) {
+	body := `{
+		"TotalResults": 2,
+		"IndexName": "collection/FooQueryModels",
+		"Results": [
+			{"DisplayName": "Foo1", "@metadata": {"@id": "foo-queries/1", "@collection": "FooQueryModels"}},
+			{"DisplayName": "Foo2", "@metadata": {"@id": "foo-queries/2", "@collection": "FooQueryModels"}}
+		]
+	}`
+	it := newStreamIterator(io.NopCloser(strings.NewReader(body)))
+	require.NoError(t, it.readHeader())
+
+	require.EqualValues(t, it.Stats().TotalResults, 2)
+	require.Equal(t, it.Stats().IndexName, "collection/FooQueryModels")
+
+	var first, second *FooQueryModel
+	require.NoError(t, it.Next(&first))
+	require.Equal(t, first.ID, "foo-queries/1")
+	require.Equal(t, first.DisplayName, "Foo1")
+	require.NoError(t, it.Next(&second))
+	require.Equal(t, second.ID, "foo-queries/2")
+
+	var model *FooQueryModel
+	require.Equal(t, it.Next(&model), ErrDone)
+	require.Equal(t, it.Next(&model), ErrDone)
+}
+
+func TestQueryStream(t *testing.T) {
+	ctx := context.Background()
+	db := initQueryTestbed(t)
+	collection := db.Collection(new(FooQueryModel))
+
+	it, err := collection.Filter("DisplayName !=", "Foo2").Stream(ctx)
+	require.NoError(t, err)
+	defer it.Close()
+
+	var ids []string
+	for {
+		var model *FooQueryModel
+		if err := it.Next(&model); err != nil {
+			if err == ErrDone {
+				break
+			}
+			require.NoError(t, err)
+		}
+		ids = append(ids, model.ID)
+	}
+	require.Equal(t, ids, []string{"foo-queries/1", "foo-queries/3"})
+}
+
+func TestQueryGetAllIDsStream(t *testing.T) {
+	var requests []string
+	db := newFakeDatabase(t, streamTestHandler(3, &requests, nil))
+
+	ids, err := db.Collection(new(FooQueryModel)).GetAllIDs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ids, []string{"foo-queries/1", "foo-queries/2", "foo-queries/3"})
+	require.Equal(t, requests, []string{"/databases/foo-db/streams/queries?metadataOnly=true"})
+}
+
+func TestQueryGetAllIDsStrongConsistency(t *testing.T) {
+	var requests []string
+	db := newFakeDatabase(t, streamTestHandler(3, &requests, nil))
+	db.strongConsistency = true
+
+	ids, err := db.Collection(new(FooQueryModel)).GetAllIDs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	require.Equal(t, requests, []string{
+		"/databases/foo-db/queries?metadataOnly=true",
+		"/databases/foo-db/streams/queries?metadataOnly=true",
+	})
+}
+
+func TestQueryDeleteEverythingChunks(t *testing.T) {
+	var requests []string
+	var batches [][]string
+	db := newFakeDatabase(t, streamTestHandler(deleteEverythingChunkSize+2, &requests, &batches))
+
+	require.NoError(t, db.Collection(new(FooQueryModel)).DeleteEverything(context.Background()))
+	require.Len(t, batches, 2)
+	require.Len(t, batches[0], deleteEverythingChunkSize)
+	require.Equal(t, batches[0][0], "foo-queries/1")
+	require.Equal(t, batches[1], []string{
+		fmt.Sprintf("foo-queries/%d", deleteEverythingChunkSize+1),
+		fmt.Sprintf("foo-queries/%d", deleteEverythingChunkSize+2),
+	})
+}
@@ -0,0 +1,400 @@
+package rdb
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Version of the TCP protocol of the subscriptions we implement.
+const subscriptionTCPVersion = 40
+
+// CreateSubscription registers a new data subscription in the server with the
+// RQL query that selects the documents it will send to the workers:
+//
+//	db.CreateSubscription(ctx, "new-orders", "from Orders where Status = 'New'")
+//
+// Subscription queries cannot have parameters.
+func (db *Database) CreateSubscription(ctx context.Context, name, query string) error {
+	if name == "" {
+		return errors.Errorf("subscription name is required to create it")
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	input := &api.SubscriptionCreation{
+		Name:  name,
+		Query: query,
+	}
+	r, err := db.conn.buildPUT(db.conn.endpoint("subscriptions"), nil, input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := db.conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return NewUnexpectedStatusError(r, resp)
+	}
+
+	return nil
+}
+
+// UpdateSubscription changes the RQL query of an existing data subscription. The
+// documents already acknowledged by the workers won't be sent again.
+func (db *Database) UpdateSubscription(ctx context.Context, name, query string) error {
+	if name == "" {
+		return errors.Errorf("subscription name is required to update it")
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	input := &api.SubscriptionCreation{
+		Name:  name,
+		Query: query,
+	}
+	r, err := db.conn.buildPOST(db.conn.endpoint("subscriptions/update"), nil, input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := db.conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusNotFound:
+		return errors.Trace(newNoSuchEntityError("subscription %q", name))
+	default:
+		return NewUnexpectedStatusError(r, resp)
+	}
+}
+
+// DeleteSubscription removes a data subscription and disconnects its workers.
+func (db *Database) DeleteSubscription(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.Errorf("subscription name is required to delete it")
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	r, err := db.conn.buildDELETE(db.conn.endpoint("subscriptions"), map[string]string{"taskName": name})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := db.conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return NewUnexpectedStatusError(r, resp)
+	}
+
+	return nil
+}
+
+// SubscriptionBatch contains the documents sent by the server in a single batch.
+type SubscriptionBatch struct {
+	results []api.Result
+}
+
+// Len returns the number of documents of the batch.
+func (batch *SubscriptionBatch) Len() int {
+	return len(batch.results)
+}
+
+// Load decodes the documents of the batch in dest, which should be a pointer to
+// a slice of models like in GetAll.
+func (batch *SubscriptionBatch) Load(dest interface{}) error {
+	rt := reflect.TypeOf(dest)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice || rt.Elem().Elem().Kind() != reflect.Ptr || rt.Elem().Elem().Elem().Kind() != reflect.Struct {
+		return errors.Errorf("dest should be a pointer to a slice of models: %T", dest)
+	}
+
+	slice := reflect.MakeSlice(rt.Elem(), 0, len(batch.results))
+	for _, result := range batch.results {
+		item := reflect.New(reflect.PtrTo(rt.Elem().Elem().Elem()))
+		if _, err := createModel(item.Interface(), result); err != nil {
+			return errors.Trace(err)
+		}
+		slice = reflect.Append(slice, item.Elem())
+	}
+	reflect.ValueOf(dest).Elem().Set(slice)
+
+	return nil
+}
+
+// SubscriptionHandler processes a batch of documents. If it returns nil the batch
+// will be acknowledged and the server will not send those documents again.
+type SubscriptionHandler func(ctx context.Context, batch *SubscriptionBatch) error
+
+type SubscriptionOption func(worker *SubscriptionWorker)
+
+// WithSubscriptionBatchSize changes the maximum number of documents the server will
+// send in each batch. By default it is 4096.
+func WithSubscriptionBatchSize(size int64) SubscriptionOption {
+	if size <= 0 {
+		panic("cannot configure a subscription batch size less than or equal to 0")
+	}
+
+	return func(worker *SubscriptionWorker) {
+		worker.batchSize = size
+	}
+}
+
+// WithSubscriptionBackoff changes the time the worker waits before reconnecting
+// after an error. It starts at min and doubles after each consecutive failure
+// until max. By default it goes from 1 second to 1 minute.
+func WithSubscriptionBackoff(min, max time.Duration) SubscriptionOption {
+	if min <= 0 || max < min {
+		panic("invalid subscription backoff range")
+	}
+
+	return func(worker *SubscriptionWorker) {
+		worker.minBackoff = min
+		worker.maxBackoff = max
+	}
+}
+
+// SubscriptionWorker connects to a data subscription and sends its documents to a
+// handler in batches.
+type SubscriptionWorker struct {
+	db   *Database
+	name string
+
+	batchSize              int64
+	minBackoff, maxBackoff time.Duration
+}
+
+// SubscriptionWorker prepares a worker for the data subscription. Call Run to start
+// receiving documents. Only one worker can be connected to the subscription at the
+// same time, the rest will wait until it is free.
+func (db *Database) SubscriptionWorker(name string, opts ...SubscriptionOption) *SubscriptionWorker {
+	worker := &SubscriptionWorker{
+		db:         db,
+		name:       name,
+		batchSize:  4096,
+		minBackoff: 1 * time.Second,
+		maxBackoff: 1 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(worker)
+	}
+	return worker
+}
+
+// subscriptionStopError is returned when the worker should not try to reconnect.
+type subscriptionStopError struct {
+	err error
+}
+
+func (err subscriptionStopError) Error() string {
+	return err.err.Error()
+}
+
+func (err subscriptionStopError) Unwrap() error {
+	return err.err
+}
+
+// Run connects to the server and calls the handler with each batch of documents
+// until the context is cancelled. Connection errors are logged and the worker
+// reconnects with an exponential backoff.
+//
+// Cancelling the context stops the worker gracefully: the batch being processed
+// will finish and be acknowledged before returning nil. The context received by
+// the handler is never cancelled for that reason. Run returns an error if the
+// handler fails or the subscription does not exist.
+func (worker *SubscriptionWorker) Run(ctx context.Context, handler SubscriptionHandler) error {
+	backoff := worker.minBackoff
+	for {
+		accepted, err := worker.connect(ctx, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var stop subscriptionStopError
+		if errors.As(err, &stop) {
+			return errors.Trace(stop.err)
+		}
+		if accepted {
+			backoff = worker.minBackoff
+		}
+		slog.Warn("RavenDB subscription connection failed, reconnecting",
+			slog.String("subscription", worker.name),
+			slog.String("error", err.Error()),
+			slog.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > worker.maxBackoff {
+			backoff = worker.maxBackoff
+		}
+	}
+}
+
+func (worker *SubscriptionWorker) connect(ctx context.Context, handler SubscriptionHandler) (bool, error) {
+	worker.db.mu.RLock()
+	conn := worker.db.conn
+	worker.db.mu.RUnlock()
+
+	info, err := conn.tcpInfo(ctx)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	u, err := url.Parse(info.URL)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	var tcp net.Conn
+	if conn.tlsConfig != nil {
+		dialer := &tls.Dialer{Config: conn.tlsConfig}
+		tcp, err = dialer.DialContext(ctx, "tcp", u.Host)
+	} else {
+		dialer := new(net.Dialer)
+		tcp, err = dialer.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer tcp.Close()
+
+	return worker.process(ctx, tcp, conn.dbname, handler)
+}
+
+// process runs the subscription protocol over an open connection. It returns if
+// the server accepted the connection to reset the backoff of the reconnections.
+func (worker *SubscriptionWorker) process(ctx context.Context, tcp net.Conn, dbname string, handler SubscriptionHandler) (bool, error) {
+	// Interrupt the reads when the context is cancelled, but keep the writes open
+	// to acknowledge the batch in progress.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = tcp.SetReadDeadline(time.Now())
+		case <-finished:
+		}
+	}()
+
+	enc := json.NewEncoder(tcp)
+	dec := json.NewDecoder(tcp)
+
+	header := &api.TCPConnectionHeader{
+		DatabaseName:     dbname,
+		Operation:        api.TCPOperationSubscription,
+		OperationVersion: subscriptionTCPVersion,
+	}
+	if err := enc.Encode(header); err != nil {
+		return false, errors.Trace(err)
+	}
+	headerResponse := new(api.TCPConnectionHeaderResponse)
+	if err := dec.Decode(headerResponse); err != nil {
+		return false, errors.Trace(err)
+	}
+	if headerResponse.Status != api.TCPStatusOk {
+		return false, subscriptionStopError{errors.Errorf("cannot open subscription connection: %s: %s", headerResponse.Status, headerResponse.Message)}
+	}
+
+	opts := &api.SubscriptionConnectionOptions{
+		SubscriptionName:                worker.name,
+		Strategy:                        "OpenIfFree",
+		MaxDocsPerBatch:                 worker.batchSize,
+		TimeToWaitBeforeConnectionRetry: api.Duration(5 * time.Second),
+	}
+	if err := enc.Encode(opts); err != nil {
+		return false, errors.Trace(err)
+	}
+
+	var accepted bool
+	var results []api.Result
+	for {
+		msg := new(api.SubscriptionServerMessage)
+		if err := dec.Decode(msg); err != nil {
+			return accepted, errors.Trace(err)
+		}
+
+		switch msg.Type {
+		case api.SubscriptionMessageConnectionStatus:
+			switch msg.Status {
+			case api.SubscriptionStatusAccepted:
+				accepted = true
+			case api.SubscriptionStatusNotFound:
+				return false, subscriptionStopError{newNoSuchEntityError("subscription %q", worker.name)}
+			case api.SubscriptionStatusClosed, api.SubscriptionStatusInvalid:
+				return false, subscriptionStopError{errors.Errorf("subscription %q %s: %s", worker.name, msg.Status, msg.Message)}
+			default:
+				return false, errors.Errorf("subscription %q %s: %s", worker.name, msg.Status, msg.Message)
+			}
+
+		case api.SubscriptionMessageData:
+			results = append(results, msg.Data)
+
+		case api.SubscriptionMessageEndOfBatch:
+			if len(results) == 0 {
+				continue
+			}
+			if err := handler(context.WithoutCancel(ctx), &SubscriptionBatch{results}); err != nil {
+				return accepted, subscriptionStopError{err}
+			}
+			ack := &api.SubscriptionClientMessage{
+				Type:         api.SubscriptionClientAcknowledge,
+				ChangeVector: results[len(results)-1].Metadata("@change-vector"),
+			}
+			if err := enc.Encode(ack); err != nil {
+				return accepted, errors.Trace(err)
+			}
+			results = nil
+
+		case api.SubscriptionMessageError:
+			return accepted, errors.Errorf("subscription %q failed: %s: %s", worker.name, msg.Message, msg.Exception)
+		}
+	}
+}
+
+func (conn *connection) tcpInfo(ctx context.Context) (*api.TCPInfo, error) {
+	r, err := conn.buildGET("/info/tcp", map[string]interface{}{"tag": conn.dbname})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := conn.sendRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewUnexpectedStatusError(r, resp)
+	}
+
+	info := new(api.TCPInfo)
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return info, nil
+}
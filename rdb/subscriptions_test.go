@@ -0,0 +1,130 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// fakeSubscriptionServer answers the handshake of the worker, sends the messages
+// and reports the acknowledged change vectors. It stops silently when the worker
+// closes the connection.
+func fakeSubscriptionServer(server net.Conn, messages []string, acks chan<- string) {
+	dec := json.NewDecoder(server)
+	header := new(api.TCPConnectionHeader)
+	if err := dec.Decode(header); err != nil || header.Operation != api.TCPOperationSubscription {
+		return
+	}
+	if _, err := server.Write([]byte(`{"Status": "Ok"}`)); err != nil {
+		return
+	}
+	opts := new(api.SubscriptionConnectionOptions)
+	if err := dec.Decode(opts); err != nil || opts.SubscriptionName != "foo-subscription" {
+		return
+	}
+
+	for _, msg := range messages {
+		if _, err := server.Write([]byte(msg)); err != nil {
+			return
+		}
+		if msg == `{"Type": "EndOfBatch"}` {
+			ack := new(api.SubscriptionClientMessage)
+			if err := dec.Decode(ack); err != nil || ack.Type != api.SubscriptionClientAcknowledge {
+				return
+			}
+			acks <- ack.ChangeVector
+		}
+	}
+}
+
+func TestSubscriptionWorkerProcess(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	acks := make(chan string, 1)
+	go fakeSubscriptionServer(server, []string{
+		`{"Type": "ConnectionStatus", "Status": "Accepted"}`,
+		`{"Type": "Data", "Data": {"DisplayName": "Foo1", "@metadata": {"@id": "foo-queries/1", "@change-vector": "A:1"}}}`,
+		`{"Type": "Data", "Data": {"DisplayName": "Foo2", "@metadata": {"@id": "foo-queries/2", "@change-vector": "A:2"}}}`,
+		`{"Type": "EndOfBatch"}`,
+	}, acks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var models []*FooQueryModel
+	worker := new(Database).SubscriptionWorker("foo-subscription")
+	var ack string
+	go func() {
+		ack = <-acks
+		cancel()
+	}()
+	accepted, err := worker.process(ctx, client, "foo-db", func(ctx context.Context, batch *SubscriptionBatch) error {
+		if batch.Len() != 2 {
+			return errors.Errorf("unexpected batch size: %d", batch.Len())
+		}
+		return errors.Trace(batch.Load(&models))
+	})
+	require.True(t, accepted)
+	require.Error(t, err)
+	require.Error(t, ctx.Err())
+	require.Equal(t, ack, "A:2")
+
+	require.Len(t, models, 2)
+	require.Equal(t, models[0].ID, "foo-queries/1")
+	require.Equal(t, models[1].DisplayName, "Foo2")
+}
+
+func TestSubscriptionWorkerNotFound(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go fakeSubscriptionServer(server, []string{
+		`{"Type": "ConnectionStatus", "Status": "NotFound"}`,
+	}, nil)
+
+	worker := new(Database).SubscriptionWorker("foo-subscription")
+	_, err := worker.process(context.Background(), client, "foo-db", func(ctx context.Context, batch *SubscriptionBatch) error {
+		return nil
+	})
+	var stop subscriptionStopError
+	require.True(t, errors.As(err, &stop))
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+}
+
+func TestSubscriptionWorkerHandlerError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go fakeSubscriptionServer(server, []string{
+		`{"Type": "ConnectionStatus", "Status": "Accepted"}`,
+		`{"Type": "Data", "Data": {"DisplayName": "Foo1", "@metadata": {"@id": "foo-queries/1", "@change-vector": "A:1"}}}`,
+		`{"Type": "EndOfBatch"}`,
+	}, nil)
+
+	errHandler := errors.New("handler failed")
+	worker := new(Database).SubscriptionWorker("foo-subscription")
+	_, err := worker.process(context.Background(), client, "foo-db", func(ctx context.Context, batch *SubscriptionBatch) error {
+		return errHandler
+	})
+	require.True(t, errors.Is(err, errHandler))
+}
+
+func TestSubscriptionCreateUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	db := initTestbed(t)
+
+	_ = db.DeleteSubscription(ctx, "foo-subscription")
+	require.NoError(t, db.CreateSubscription(ctx, "foo-subscription", "from FooQueryModels"))
+	require.NoError(t, db.UpdateSubscription(ctx, "foo-subscription", "from FooQueryModels where DisplayName = 'Foo1'"))
+	require.NoError(t, db.DeleteSubscription(ctx, "foo-subscription"))
+}
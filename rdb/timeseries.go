@@ -0,0 +1,260 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/naming"
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Maximum date of a time series, used when the end of a range is not specified.
+var maxTimeSeriesDate = time.Date(9999, time.December, 31, 23, 59, 59, 999999900, time.UTC)
+
+// TimeSeriesEntry is a single value of a time series.
+type TimeSeriesEntry struct {
+	Timestamp time.Time
+	Values    []float64
+	Tag       string
+
+	// The entry was aggregated by a rollup policy.
+	IsRollup bool
+}
+
+// TimeSeries is a list of numeric values over time attached to a document.
+type TimeSeries struct {
+	conn        *connection
+	sess        *Session
+	docID, name string
+}
+
+// TimeSeries returns an accessor for the time series of the document. Ranges
+// included when loading the document are read from the session without
+// contacting the server.
+func (sess *Session) TimeSeries(docID, name string) *TimeSeries {
+	return &TimeSeries{
+		conn:  sess.conn,
+		sess:  sess,
+		docID: docID,
+		name:  name,
+	}
+}
+
+// Append adds a new entry to the time series. If there is an entry with the same
+// timestamp it will be replaced. The tag is optional.
+func (ts *TimeSeries) Append(ctx context.Context, t time.Time, values []float64, tag string) error {
+	if len(values) == 0 {
+		return errors.Errorf("time series entries should have at least one value")
+	}
+
+	op := &api.TimeSeriesOperation{
+		Name: ts.name,
+		Appends: []*api.TimeSeriesAppend{
+			{
+				Timestamp: formatTimeSeriesDate(t),
+				Values:    values,
+				Tag:       tag,
+			},
+		},
+	}
+	return errors.Trace(ts.send(ctx, op))
+}
+
+// Delete removes the entries between from and to, both included. Zero times
+// leave the range open at that side.
+func (ts *TimeSeries) Delete(ctx context.Context, from, to time.Time) error {
+	del := new(api.TimeSeriesDelete)
+	if !from.IsZero() {
+		s := formatTimeSeriesDate(from)
+		del.From = &s
+	}
+	if !to.IsZero() {
+		s := formatTimeSeriesDate(to)
+		del.To = &s
+	}
+	op := &api.TimeSeriesOperation{
+		Name:    ts.name,
+		Deletes: []*api.TimeSeriesDelete{del},
+	}
+	return errors.Trace(ts.send(ctx, op))
+}
+
+func (ts *TimeSeries) send(ctx context.Context, op *api.TimeSeriesOperation) error {
+	r, err := ts.conn.buildPOST(ts.conn.endpoint("timeseries"), map[string]interface{}{"docId": ts.docID}, op)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := ts.conn.sendRequest(ctx, r)
+	if err != nil {
+		var unexpected UnexpectedStatusError
+		if errors.As(err, &unexpected) && unexpected.Advanced != nil && unexpected.Advanced.Type == "Raven.Client.Exceptions.Documents.DocumentDoesNotExistException" {
+			return newNoSuchEntityError("document %q does not exists when updating time series %q", ts.docID, ts.name)
+		}
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		ts.sess.forgetTimeSeries(ts.docID, ts.name)
+		return nil
+	case http.StatusNotFound:
+		return newNoSuchEntityError("document %q does not exists when updating time series %q", ts.docID, ts.name)
+	default:
+		return NewUnexpectedStatusError(r, resp)
+	}
+}
+
+// Get returns the entries between from and to, both included, in chronological
+// order. Zero times leave the range open at that side. If the document or the
+// time series do not exist it returns an empty list.
+func (ts *TimeSeries) Get(ctx context.Context, from, to time.Time) ([]TimeSeriesEntry, error) {
+	if entries, ok := ts.sess.includedTimeSeries(ts.docID, ts.name, from, to); ok {
+		return entries, nil
+	}
+
+	params := map[string]interface{}{
+		"docId": ts.docID,
+		"name":  ts.name,
+		"from":  formatTimeSeriesDate(from),
+		"to":    formatTimeSeriesEnd(to),
+	}
+	r, err := ts.conn.buildGET(ts.conn.endpoint("timeseries"), params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := ts.conn.sendRequest(ctx, r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result := new(api.TimeSeriesRangeResult)
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return parseTimeSeriesEntries(result.Entries)
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, NewUnexpectedStatusError(r, resp)
+	}
+}
+
+func timeSeriesIncludeParams(cnf *includesConfig, params map[string]interface{}) {
+	if len(cnf.timeSeries) == 0 {
+		return
+	}
+	var names, from, to []string
+	for _, include := range cnf.timeSeries {
+		names = append(names, include.name)
+		from = append(from, formatTimeSeriesDate(include.from))
+		to = append(to, formatTimeSeriesEnd(include.to))
+	}
+	params["timeseries"] = names
+	params["from"] = from
+	params["to"] = to
+}
+
+type timeSeriesRange struct {
+	from, to time.Time
+	entries  []TimeSeriesEntry
+}
+
+func timeSeriesKey(docID, name string) string {
+	return naming.Generate(strings.ToLower(docID), strings.ToLower(name))
+}
+
+func (sess *Session) mergeTimeSeries(includes map[string]map[string][]*api.TimeSeriesRangeResult) error {
+	if sess == nil {
+		return nil
+	}
+	if sess.timeSeries == nil {
+		sess.timeSeries = make(map[string][]*timeSeriesRange)
+	}
+	for docID, series := range includes {
+		for name, ranges := range series {
+			for _, result := range ranges {
+				entries, err := parseTimeSeriesEntries(result.Entries)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				rng := &timeSeriesRange{
+					to:      maxTimeSeriesDate,
+					entries: entries,
+				}
+				if result.From != nil {
+					if rng.from, err = time.Parse(api.DateTimeFormat, *result.From); err != nil {
+						return errors.Trace(err)
+					}
+				}
+				if result.To != nil {
+					if rng.to, err = time.Parse(api.DateTimeFormat, *result.To); err != nil {
+						return errors.Trace(err)
+					}
+				}
+				key := timeSeriesKey(docID, name)
+				sess.timeSeries[key] = append(sess.timeSeries[key], rng)
+			}
+		}
+	}
+	return nil
+}
+
+func (sess *Session) includedTimeSeries(docID, name string, from, to time.Time) ([]TimeSeriesEntry, bool) {
+	if to.IsZero() {
+		to = maxTimeSeriesDate
+	}
+	for _, rng := range sess.timeSeries[timeSeriesKey(docID, name)] {
+		if from.Before(rng.from) || to.After(rng.to) {
+			continue
+		}
+		var entries []TimeSeriesEntry
+		for _, entry := range rng.entries {
+			if !entry.Timestamp.Before(from) && !entry.Timestamp.After(to) {
+				entries = append(entries, entry)
+			}
+		}
+		return entries, true
+	}
+	return nil, false
+}
+
+func (sess *Session) forgetTimeSeries(docID, name string) {
+	delete(sess.timeSeries, timeSeriesKey(docID, name))
+}
+
+func parseTimeSeriesEntries(entries []*api.TimeSeriesEntry) ([]TimeSeriesEntry, error) {
+	result := make([]TimeSeriesEntry, len(entries))
+	for i, entry := range entries {
+		timestamp, err := time.Parse(api.DateTimeFormat, entry.Timestamp)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result[i] = TimeSeriesEntry{
+			Timestamp: timestamp,
+			Values:    entry.Values,
+			Tag:       entry.Tag,
+			IsRollup:  entry.IsRollup,
+		}
+	}
+	return result, nil
+}
+
+func formatTimeSeriesDate(t time.Time) string {
+	return t.In(time.UTC).Format(api.DateTimeFormat)
+}
+
+func formatTimeSeriesEnd(t time.Time) string {
+	if t.IsZero() {
+		return formatTimeSeriesDate(maxTimeSeriesDate)
+	}
+	return formatTimeSeriesDate(t)
+}
@@ -0,0 +1,191 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/stretchr/testify/require"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+const timeSeriesTestEntries = `[
+	{"Timestamp": "2020-01-01T10:00:00.0000000Z", "Values": [1, 2], "Tag": "foo"},
+	{"Timestamp": "2020-01-02T10:00:00.0000000Z", "Values": [3, 4], "IsRollup": true}
+]`
+
+func TestTimeSeriesAppendDelete(t *testing.T) {
+	var docID string
+	var ops []map[string]interface{}
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		docID = r.URL.Query().Get("docId")
+		var op map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&op)
+		ops = append(ops, op)
+		if docID == "foo-collections/2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ctx, sess := db.NewSession(context.Background())
+
+	ts := sess.TimeSeries("foo-collections/1", "Prices")
+	require.NoError(t, ts.Append(ctx, time.Date(2020, time.January, 1, 10, 0, 0, 0, time.UTC), []float64{1, 2}, "foo"))
+	require.NoError(t, ts.Delete(ctx, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), time.Time{}))
+	require.Equal(t, docID, "foo-collections/1")
+	require.Equal(t, ops, []map[string]interface{}{
+		{
+			"Name": "Prices",
+			"Appends": []interface{}{
+				map[string]interface{}{
+					"Timestamp": "2020-01-01T10:00:00.0000000Z",
+					"Values":    []interface{}{float64(1), float64(2)},
+					"Tag":       "foo",
+				},
+			},
+		},
+		{
+			"Name": "Prices",
+			"Deletes": []interface{}{
+				map[string]interface{}{
+					"From": "2020-01-01T00:00:00.0000000Z",
+					"To":   nil,
+				},
+			},
+		},
+	})
+
+	err := sess.TimeSeries("foo-collections/2", "Prices").Append(ctx, time.Now(), []float64{1}, "")
+	require.True(t, errors.Is(err, ErrNoSuchEntity))
+
+	require.Error(t, ts.Append(ctx, time.Now(), nil, ""))
+}
+
+func TestTimeSeriesGet(t *testing.T) {
+	var query url.Values
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		if query.Get("name") == "Missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Entries": ` + timeSeriesTestEntries + `, "TotalResults": 2}`))
+	}))
+	ctx, sess := db.NewSession(context.Background())
+
+	entries, err := sess.TimeSeries("foo-collections/1", "Prices").Get(ctx, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, query.Get("docId"), "foo-collections/1")
+	require.Equal(t, query.Get("from"), "2020-01-01T00:00:00.0000000Z")
+	require.Equal(t, query.Get("to"), "9999-12-31T23:59:59.9999999Z")
+	require.Equal(t, entries, []TimeSeriesEntry{
+		{
+			Timestamp: time.Date(2020, time.January, 1, 10, 0, 0, 0, time.UTC),
+			Values:    []float64{1, 2},
+			Tag:       "foo",
+		},
+		{
+			Timestamp: time.Date(2020, time.January, 2, 10, 0, 0, 0, time.UTC),
+			Values:    []float64{3, 4},
+			IsRollup:  true,
+		},
+	})
+
+	entries, err = sess.TimeSeries("foo-collections/1", "Missing").Get(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestTimeSeriesIncludes(t *testing.T) {
+	var requests []*url.URL
+	db := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL)
+		_, _ = w.Write([]byte(`{
+			"Results": [{"@metadata": {"@id": "foo-collections/1", "@change-vector": "A:1"}}],
+			"TimeSeriesIncludes": {
+				"foo-collections/1": {
+					"Prices": [{"From": "2020-01-01T00:00:00.0000000Z", "To": null, "Entries": ` + timeSeriesTestEntries + `}]
+				}
+			}
+		}`))
+	}))
+	ctx, sess := db.NewSession(context.Background())
+	collection := db.Collection(new(FooCollectionModel))
+
+	var foo *FooCollectionModel
+	from := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, collection.Get(ctx, "foo-collections/1", &foo, IncludeTimeSeries("Prices", from, time.Time{})))
+	require.Len(t, requests, 1)
+	require.Equal(t, requests[0].Query()["timeseries"], []string{"Prices"})
+	require.Equal(t, requests[0].Query()["from"], []string{"2020-01-01T00:00:00.0000000Z"})
+	require.Equal(t, requests[0].Query()["to"], []string{"9999-12-31T23:59:59.9999999Z"})
+
+	entries, err := sess.TimeSeries("FOO-COLLECTIONS/1", "prices").Get(ctx, time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	require.Len(t, entries, 1)
+	require.Equal(t, entries[0].Values, []float64{3, 4})
+}
+
+func TestConfigureTimeSeries(t *testing.T) {
+	op := ConfigureTimeSeries()
+	op.SetRawRetention("Hotels", 24*time.Hour)
+	op.AddRollup("Hotels", "ByHour", time.Hour, 0)
+
+	require.Equal(t, op.URL(), "/admin/timeseries/config")
+	require.Equal(t, op.Body(), &api.TimeSeriesConfiguration{
+		Collections: map[string]*api.TimeSeriesCollectionConfiguration{
+			"Hotels": {
+				RawPolicy: &api.TimeSeriesPolicy{
+					Name:          "rawpolicy",
+					RetentionTime: api.TimeValue{Value: 86400, Unit: "Second"},
+				},
+				Policies: []*api.TimeSeriesPolicy{
+					{
+						Name:            "ByHour",
+						AggregationTime: &api.TimeValue{Value: 3600, Unit: "Second"},
+						RetentionTime:   api.TimeValue{Value: 2147483647, Unit: "None"},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestTimeSeries(t *testing.T) {
+	ctx := context.Background()
+	db := initCollectionTestbed(t)
+	collection := db.Collection(new(FooCollectionModel))
+
+	foo := &FooCollectionModel{
+		ID: "foo-collections/timeseries",
+	}
+	require.NoError(t, collection.Put(ctx, foo))
+
+	ctx, sess := db.NewSession(ctx)
+	ts := sess.TimeSeries(foo.ID, "Prices")
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ts.Append(ctx, start.Add(time.Duration(i)*time.Hour), []float64{float64(i)}, "foo"))
+	}
+	require.NoError(t, ts.Delete(ctx, start.Add(2*time.Hour), time.Time{}))
+
+	entries, err := ts.Get(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, entries[1].Values, []float64{1})
+	require.Equal(t, entries[1].Tag, "foo")
+
+	ctx, sess = db.NewSession(context.Background())
+	var other *FooCollectionModel
+	require.NoError(t, collection.Get(ctx, foo.ID, &other, IncludeTimeSeries("Prices", start, time.Time{})))
+	entries, err = sess.TimeSeries(foo.ID, "Prices").Get(ctx, start.Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
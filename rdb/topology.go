@@ -0,0 +1,159 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+
+	"libs.altipla.consulting/rdb/api"
+)
+
+// Time after which the topology is fetched again from the server in the
+// background, returning to the preferred node if we failed over to another one.
+const topologyRefreshInterval = 5 * time.Minute
+
+// updateTopology reads the nodes of the database from the server. The first
+// node of the topology is the preferred one and will receive the requests.
+func (conn *connection) updateTopology(ctx context.Context) error {
+	r, err := conn.buildGET("/topology", map[string]interface{}{"name": conn.dbname})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := conn.sendRequest(ctx, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		topology := new(api.Topology)
+		if err := json.NewDecoder(resp.Body).Decode(topology); err != nil {
+			return errors.Trace(err)
+		}
+
+		var nodes []*url.URL
+		for _, node := range topology.Nodes {
+			u, err := url.Parse(node.URL)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			nodes = append(nodes, u)
+		}
+
+		conn.configmux.Lock()
+		defer conn.configmux.Unlock()
+
+		conn.topologyUpdated = time.Now()
+
+		// A single node is not replicated and we keep the configured address, that
+		// may be different from the URL the node knows about itself, e.g. in Docker.
+		if len(nodes) < 2 || topology.Etag < conn.topologyEtag {
+			return nil
+		}
+		conn.topologyEtag = topology.Etag
+		conn.nodes = nodes
+		conn.base = nodes[0]
+
+		return nil
+
+	case http.StatusNotFound:
+		// The database does not exist yet, keep using the initial node.
+		return nil
+
+	default:
+		return NewUnexpectedStatusError(r, resp)
+	}
+}
+
+// refreshTopology updates the topology in the background if the server asks for
+// it or it is too old.
+func (conn *connection) refreshTopology(resp *http.Response) {
+	conn.configmux.RLock()
+	expired := len(conn.nodes) > 0 && time.Since(conn.topologyUpdated) > topologyRefreshInterval
+	conn.configmux.RUnlock()
+
+	if !expired && resp.Header.Get("Refresh-Topology") != "true" {
+		return
+	}
+	conn.updateTopologyBackground()
+}
+
+// updateTopologyBackground updates the topology in a new goroutine unless there
+// is another update running.
+func (conn *connection) updateTopologyBackground() {
+	if !conn.refreshingTopology.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer conn.refreshingTopology.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := conn.updateTopology(ctx); err != nil {
+			slog.Warn("Cannot refresh RavenDB topology", slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// failoverNodes returns the nodes that can receive the request, starting with
+// the node it was built for.
+func (conn *connection) failoverNodes(r *http.Request) []*url.URL {
+	conn.configmux.RLock()
+	defer conn.configmux.RUnlock()
+
+	current := &url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host}
+	nodes := []*url.URL{current}
+	if !canRetry(r) {
+		return nodes
+	}
+	for _, node := range conn.nodes {
+		if node.Scheme != current.Scheme || node.Host != current.Host {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// failover changes the node that will receive the next requests after the
+// current one failed.
+func (conn *connection) failover(failed, next *url.URL) {
+	conn.configmux.Lock()
+	defer conn.configmux.Unlock()
+
+	if conn.base.Scheme == failed.Scheme && conn.base.Host == failed.Host {
+		conn.base = next
+	}
+}
+
+// canRetry returns true if the request can be sent again to another node without
+// side effects if the first one fails in the middle.
+func canRetry(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	case http.MethodPut:
+		// Only writes to a concrete document can be repeated. Administrative
+		// operations and IDs generated by the server would be applied twice.
+		if strings.Contains(r.URL.Path, "/admin/") {
+			return false
+		}
+		id := r.URL.Query().Get("id")
+		return id != "" && !strings.HasSuffix(id, "|") && !strings.HasSuffix(id, "/")
+	case http.MethodPost:
+		// Queries are sent with POST but they only read data.
+		return strings.HasSuffix(r.URL.Path, "/queries")
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTopologyTestConnection(t *testing.T, addresses ...string) *connection {
+	conn, err := newConnection(addresses[0], "foo-db", false)
+	require.NoError(t, err)
+	for _, address := range addresses {
+		u, err := url.Parse(address)
+		require.NoError(t, err)
+		conn.nodes = append(conn.nodes, u)
+	}
+
+	// Avoid background refreshes of the topology against the test servers.
+	conn.topologyUpdated = time.Now()
+
+	return conn
+}
+
+func TestUpdateTopology(t *testing.T) {
+	var requested *url.URL
+	conn := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL
+		fmt.Fprint(w, `{"Etag": 3, "Nodes": [{"Url": "http://node-b:8080", "ClusterTag": "B"}, {"Url": "http://node-a:8080", "ClusterTag": "A"}]}`)
+	})).conn
+	require.NoError(t, conn.updateTopology(context.Background()))
+	require.Equal(t, requested.Path, "/topology")
+	require.Equal(t, requested.Query().Get("name"), "foo-db")
+
+	require.Len(t, conn.nodes, 2)
+	require.EqualValues(t, conn.topologyEtag, 3)
+	require.Equal(t, conn.base.String(), "http://node-b:8080")
+}
+
+func TestUpdateTopologySingleNode(t *testing.T) {
+	conn := newFakeDatabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Etag": 1, "Nodes": [{"Url": "http://container:8080", "ClusterTag": "A"}]}`)
+	})).conn
+	base := conn.base.String()
+	require.NoError(t, conn.updateTopology(context.Background()))
+
+	require.Empty(t, conn.nodes)
+	require.Equal(t, conn.base.String(), base)
+}
+
+func TestFailoverUnavailableNode(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	var body string
+	available := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 64)
+		n, _ := r.Body.Read(b)
+		body = string(b[:n])
+		fmt.Fprint(w, `{}`)
+	}))
+	defer available.Close()
+
+	conn := newTopologyTestConnection(t, unavailable.URL, available.URL)
+
+	r, err := conn.buildPOST(conn.endpoint("queries"), nil, map[string]string{"Query": "from Foos"})
+	require.NoError(t, err)
+	resp, err := conn.sendRequest(context.Background(), r)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Equal(t, resp.StatusCode, http.StatusOK)
+	require.Equal(t, body, `{"Query":"from Foos"}`+"\n")
+	require.Equal(t, conn.base.String(), available.URL)
+}
+
+func TestFailoverNetworkError(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	available := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer available.Close()
+
+	conn := newTopologyTestConnection(t, down.URL, available.URL)
+
+	r, err := conn.buildGET(conn.endpoint("docs"), map[string]interface{}{"id": "foo"})
+	require.NoError(t, err)
+	resp, err := conn.sendRequest(context.Background(), r)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Equal(t, conn.base.String(), available.URL)
+}
+
+func TestFailoverNotRetryable(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	var called bool
+	available := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer available.Close()
+
+	conn := newTopologyTestConnection(t, unavailable.URL, available.URL)
+
+	r, err := conn.buildPOST(conn.endpoint("bulk_docs"), nil, map[string]string{})
+	require.NoError(t, err)
+	_, err = conn.sendRequest(context.Background(), r)
+	require.Error(t, err)
+
+	require.False(t, called)
+	require.Equal(t, conn.base.String(), unavailable.URL)
+}
+
+func TestCanRetry(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		retry  bool
+	}{
+		{http.MethodGet, "/databases/foo-db/docs?id=foos/1", true},
+		{http.MethodPost, "/databases/foo-db/queries", true},
+		{http.MethodPost, "/databases/foo-db/bulk_docs", false},
+		{http.MethodPut, "/databases/foo-db/docs?id=foos/1", true},
+		{http.MethodPut, "/databases/foo-db/docs?id=foos|", false},
+		{http.MethodPut, "/databases/foo-db/docs?id=foos/", false},
+		{http.MethodPut, "/databases/foo-db/admin/indexes", false},
+		{http.MethodPut, "/admin/databases?name=foo-db", false},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest(test.method, "http://localhost:13000"+test.path, nil)
+		require.NoError(t, err)
+		require.Equal(t, canRetry(r), test.retry, test.method+" "+test.path)
+	}
+}